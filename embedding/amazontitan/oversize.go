@@ -0,0 +1,123 @@
+package amazontitan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+)
+
+// OversizeStrategy controls how TitanEmbedder handles inputs that exceed the
+// model input limit (about 8k tokens for Titan text embeddings).
+type OversizeStrategy int
+
+const (
+	// OversizePassthrough sends inputs as they are and leaves the limit to
+	// Titan. It is the default: the token estimate errs on the high side, so
+	// it would reject some inputs that Titan accepts.
+	OversizePassthrough OversizeStrategy = iota
+	// OversizeError fails the call with ErrInputTooLong before invoking Titan.
+	OversizeError
+	// OversizeTruncateTail drops the end of the input and embeds its beginning.
+	OversizeTruncateTail
+	// OversizeTruncateHead drops the beginning of the input and embeds its end.
+	OversizeTruncateHead
+	// OversizeSplit splits the input on token boundaries, embeds every chunk and
+	// averages the chunk vectors, weighted by chunk length.
+	OversizeSplit
+)
+
+var ErrInputTooLong = errors.New("input exceeds the model token limit")
+
+// TruncatedInput describes an input that was shortened before being embedded.
+type TruncatedInput struct {
+	// Index is the position of the input in the texts passed to EmbedDocuments
+	// (always 0 for EmbedQuery).
+	Index           int
+	EstimatedTokens int
+	KeptTokens      int
+}
+
+// fitInput returns the text(s) to embed for the input at position index so
+// that each of them is within MaxChunkTokens.
+func (te *TitanEmbedder) fitInput(index int, text string) ([]string, error) {
+
+	strategy := te.oversize()
+	if strategy == OversizePassthrough {
+		return []string{text}, nil
+	}
+
+	maxTokens := te.maxChunkTokens()
+	counter := te.tokenCounter()
+
+	tokens := counter.CountTokens(text)
+	if tokens <= maxTokens {
+		return []string{text}, nil
+	}
+
+	var kept string
+
+	switch strategy {
+	case OversizeSplit:
+		return splitOnTokenBoundaries(counter, text, maxTokens), nil
+	case OversizeTruncateTail:
+		kept = keepHead(counter, text, maxTokens)
+	case OversizeTruncateHead:
+		kept = keepTail(counter, text, maxTokens)
+	default:
+		return nil, fmt.Errorf("%w: input %d has an estimated %d tokens (limit %d)", ErrInputTooLong, index, tokens, maxTokens)
+	}
+
+	if te.OnTruncate != nil {
+		te.OnTruncate(TruncatedInput{Index: index, EstimatedTokens: tokens, KeptTokens: counter.CountTokens(kept)})
+	}
+
+	return []string{kept}, nil
+}
+
+// keepHead returns the longest prefix of text that fits in maxTokens.
+func keepHead(counter llm.TokenCounter, text string, maxTokens int) string {
+	var kept strings.Builder
+	tokens := 0
+
+	for _, piece := range tokenPieces(text) {
+		pt := counter.CountTokens(piece)
+		if tokens+pt > maxTokens {
+			if kept.Len() == 0 {
+				return splitPiece(counter, piece, maxTokens)[0]
+			}
+			break
+		}
+		kept.WriteString(piece)
+		tokens += pt
+	}
+
+	return kept.String()
+}
+
+// keepTail returns the longest suffix of text that fits in maxTokens.
+func keepTail(counter llm.TokenCounter, text string, maxTokens int) string {
+	pieces := tokenPieces(text)
+	tokens := 0
+	start := len(pieces)
+
+	for i := len(pieces) - 1; i >= 0; i-- {
+		pt := counter.CountTokens(pieces[i])
+		if tokens+pt > maxTokens {
+			break
+		}
+		tokens += pt
+		start = i
+	}
+
+	if start == len(pieces) {
+		runes := []rune(pieces[len(pieces)-1])
+		n := longestFitting(len(runes), func(n int) bool {
+			return counter.CountTokens(string(runes[len(runes)-n:])) <= maxTokens
+		})
+		return string(runes[len(runes)-n:])
+	}
+
+	return strings.Join(pieces[start:], "")
+}
@@ -4,22 +4,52 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"strings"
+	"time"
 
 	titan_embedding "github.com/abhirockzz/amazon-bedrock-go-inference-params/amazontitan/embedding"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/embeddings"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TitanEmbedder struct {
-	brc *bedrockruntime.Client
+	// CallbacksHandler receives retry and circuit breaker events and, if it
+	// implements llm.EmbeddingHandler, embedding start, end and error events.
+	CallbacksHandler callbacks.Handler
+
+	brc         llm.RuntimeClient
+	modelID     string
+	baseModelID string
+	logger      *slog.Logger
+	tracer      trace.Tracer
+	metrics     llm.MetricsRecorder
+	limiter     *llm.RateLimiter
+	tokens      llm.TokenCounter
 
 	StripNewLines bool
-	BatchSize     int
+	// Deprecated: BatchSize is ignored. Titan embeds one text per request.
+	BatchSize int
+
+	// SplitLongTexts enables the long-text mode. A document whose estimated token
+	// count exceeds MaxChunkTokens is split on token boundaries, each chunk is
+	// embedded and the chunk vectors are averaged (weighted by chunk length) into
+	// a single vector for that document. It is the same as OversizeSplit.
+	SplitLongTexts bool
+	MaxChunkTokens int
+
+	// Oversize decides what happens to an input whose estimated token count
+	// exceeds MaxChunkTokens, when SplitLongTexts is not set. By default the
+	// input is sent as it is.
+	Oversize OversizeStrategy
+	// OnTruncate, if set, is called for every input shortened by one of the
+	// truncating strategies.
+	OnTruncate func(TruncatedInput)
 }
 
 var _ embeddings.Embedder = &TitanEmbedder{}
@@ -28,62 +58,118 @@ var (
 	ErrMissingRegion = errors.New("empty region")
 )
 
-func New(region string) (*TitanEmbedder, error) {
+// New creates a TitanEmbedder. Of the llm.ConfigOption values, the Bedrock
+// runtime client, failover, model, logging, tracing, metrics, rate limiter and
+// token counter options apply.
+func New(region string, options ...llm.ConfigOption) (*TitanEmbedder, error) {
 
 	if region == "" {
 		return nil, ErrMissingRegion
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	te := &TitanEmbedder{
+		modelID:        titanEmbeddingModelID,
+		logger:         llm.NopLogger(),
+		MaxChunkTokens: defaultMaxChunkTokens,
+	}
+
+	opts := &llm.ConfigOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	brc, err := llm.NewRuntimeClient(context.Background(), region, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TitanEmbedder{
-		brc: bedrockruntime.NewFromConfig(cfg),
-	}, nil
+	te.brc = brc
 
-}
+	if opts.ModelID != "" {
+		te.modelID = opts.ModelID
+	}
 
-func (te *TitanEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	te.baseModelID = llm.ResolveBaseModel(te.modelID, opts)
+
+	if err := llm.CheckModel(te.baseModelID, llm.ProviderAmazon, llm.ModalityEmbedding); err != nil {
+		return nil, err
+	}
+
+	if opts.Logger != nil {
+		te.logger = opts.Logger
+	}
 
-	log.Println("ENTER TitanEmbedder/EmbedDocuments")
+	te.tracer = llm.Tracer(opts.TracerProvider)
+	te.metrics = opts.Metrics
+	te.limiter = opts.RateLimiter
 
-	batchedTexts := embeddings.BatchTexts(
-		embeddings.MaybeRemoveNewLines(texts, te.StripNewLines),
-		te.BatchSize,
-	)
+	te.tokens = opts.TokenCounter
+	if te.tokens == nil {
+		te.tokens = llm.TokenCounterFor(te.baseModelID)
+	}
+
+	return te, nil
+}
 
-	log.Println("output after creating batched texts")
+// EmbedDocuments returns one vector for each of the texts, in order.
+func (te *TitanEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	te.handleStart(ctx, texts)
 
-	for _, text := range batchedTexts {
-		fmt.Println(text)
+	emb, err := te.embedDocuments(ctx, texts)
+	if err != nil {
+		te.handleError(ctx, err)
+		return nil, err
 	}
 
+	te.handleEnd(ctx, texts, emb)
+	return emb, nil
+}
+
+func (te *TitanEmbedder) embedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+
 	emb := make([][]float32, 0, len(texts))
 
-	for _, texts := range batchedTexts {
-		curTextEmbeddings, err := te.createEmbedding(ctx, texts)
+	for i, text := range embeddings.MaybeRemoveNewLines(texts, te.StripNewLines) {
+		textEmbedding, err := te.embedDocument(ctx, i, text)
 		if err != nil {
 			return nil, err
 		}
 
-		textLengths := make([]int, 0, len(texts))
-		for _, text := range texts {
-			textLengths = append(textLengths, len(text))
-		}
+		emb = append(emb, textEmbedding)
+	}
 
-		combined, err := embeddings.CombineVectors(curTextEmbeddings, textLengths)
-		if err != nil {
-			return nil, err
-		}
+	return emb, nil
+}
+
+// embedDocument creates the embedding for the document at position index. In
+// long-text mode, or with another Oversize strategy, an oversized document is
+// split or truncated first.
+func (te *TitanEmbedder) embedDocument(ctx context.Context, index int, text string) ([]float32, error) {
 
-		emb = append(emb, combined)
+	chunks, err := te.fitInput(index, text)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("EXIT TitanEmbedder/EmbedDocuments")
+	chunkEmbeddings, err := te.createEmbedding(ctx, chunks)
+	if err != nil {
+		return nil, err
+	}
 
-	return emb, nil
+	if te.metrics != nil {
+		te.metrics.RecordEmbeddedTexts(ctx, te.modelID, 1)
+	}
+
+	if len(chunkEmbeddings) == 1 {
+		return chunkEmbeddings[0], nil
+	}
+
+	chunkLengths := make([]int, 0, len(chunks))
+	for _, chunk := range chunks {
+		chunkLengths = append(chunkLengths, len(chunk))
+	}
+
+	return embeddings.CombineVectors(chunkEmbeddings, chunkLengths)
 }
 
 func (te *TitanEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
@@ -91,21 +177,78 @@ func (te *TitanEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32
 		text = strings.ReplaceAll(text, "\n", " ")
 	}
 
-	emb, err := te.createEmbedding(ctx, []string{text})
+	te.handleStart(ctx, []string{text})
+
+	emb, err := te.embedDocument(ctx, 0, text)
 	if err != nil {
+		te.handleError(ctx, err)
 		return nil, err
 	}
 
-	return emb[0], nil
+	te.handleEnd(ctx, []string{text}, [][]float32{emb})
+	return emb, nil
+}
+
+func (te *TitanEmbedder) handleStart(ctx context.Context, texts []string) {
+	if h, ok := te.CallbacksHandler.(llm.EmbeddingHandler); ok {
+		h.HandleEmbeddingStart(ctx, texts)
+	}
+}
+
+func (te *TitanEmbedder) handleEnd(ctx context.Context, texts []string, emb [][]float32) {
+	if h, ok := te.CallbacksHandler.(llm.EmbeddingHandler); ok {
+		h.HandleEmbeddingEnd(ctx, texts, emb)
+	}
+}
+
+func (te *TitanEmbedder) handleError(ctx context.Context, err error) {
+	if h, ok := te.CallbacksHandler.(llm.EmbeddingHandler); ok {
+		h.HandleEmbeddingError(ctx, err)
+	}
 }
 
 const (
-	titanEmbeddingModelID = "amazon.titan-embed-text-v1" //https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+	titanEmbeddingModelID = llm.ModelTitanEmbedText
+
+	defaultMaxChunkTokens = 8000
 )
 
-func (te *TitanEmbedder) createEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+// ModelID returns the Bedrock model used to create the embeddings.
+func (te *TitanEmbedder) ModelID() string {
+	return te.modelID
+}
 
-	log.Println("ENTER TitanEmbedder/createEmbedding")
+// Parameters returns the settings that influence the vectors produced by te.
+func (te *TitanEmbedder) Parameters() map[string]string {
+	return map[string]string{
+		"strip_new_lines":  strconv.FormatBool(te.StripNewLines),
+		"oversize":         strconv.Itoa(int(te.oversize())),
+		"max_input_tokens": strconv.Itoa(te.maxChunkTokens()),
+	}
+}
+
+func (te *TitanEmbedder) maxChunkTokens() int {
+	if te.MaxChunkTokens <= 0 {
+		return defaultMaxChunkTokens
+	}
+	return te.MaxChunkTokens
+}
+
+func (te *TitanEmbedder) tokenCounter() llm.TokenCounter {
+	if te.tokens == nil {
+		return llm.TitanEstimator
+	}
+	return te.tokens
+}
+
+func (te *TitanEmbedder) oversize() OversizeStrategy {
+	if te.SplitLongTexts {
+		return OversizeSplit
+	}
+	return te.Oversize
+}
+
+func (te *TitanEmbedder) createEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
 
 	embeddings := make([][]float32, 0, len(texts))
 
@@ -122,31 +265,59 @@ func (te *TitanEmbedder) createEmbedding(ctx context.Context, texts []string) ([
 			return nil, err
 		}
 
-		log.Println("creating embedding for", input)
-
-		output, err := te.brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-			Body:        payloadBytes,
-			ModelId:     aws.String(titanEmbeddingModelID),
-			ContentType: aws.String("application/json"),
-		})
+		llm.LogPayload(ctx, te.logger, te.modelID, payloadBytes)
 
+		resp, err := te.invoke(ctx, payloadBytes, te.tokenCounter().CountTokens(input))
 		if err != nil {
 			return nil, err
 		}
 
-		var resp titan_embedding.Response
+		embeddings = append(embeddings, resp.Embedding)
+	}
 
-		err = json.Unmarshal(output.Body, &resp)
+	return embeddings, nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+func (te *TitanEmbedder) invoke(ctx context.Context, payloadBytes []byte, estimatedTokens int) (titan_embedding.Response, error) {
 
-		log.Println("finished creating embedding for", input)
-		embeddings = append(embeddings, resp.Embedding)
+	ctx, span := llm.StartSpan(ctx, te.tracer, llm.SpanParams{Operation: llm.OperationEmbeddings, ModelID: te.modelID})
+
+	reservation, err := te.limiter.Reserve(ctx, te.modelID, estimatedTokens)
+	if err != nil {
+		llm.EndSpan(span, llm.Usage{}, "", err)
+		return titan_embedding.Response{}, err
 	}
 
-	log.Println("EXIT TitanEmbedder/createEmbedding")
+	var attempts llm.Attempts
+	start := time.Now()
 
-	return embeddings, nil
+	output, err := te.brc.InvokeModel(llm.ContextWithReservation(llm.ContextWithCallbacks(ctx, te.CallbacksHandler), reservation), &bedrockruntime.InvokeModelInput{
+		Body:        payloadBytes,
+		ModelId:     aws.String(te.modelID),
+		ContentType: aws.String("application/json"),
+	}, append(llm.RetryCallbacks(ctx, te.CallbacksHandler), attempts.ClientOption())...)
+
+	if err != nil {
+		reservation.Settle(llm.Usage{})
+		llm.ObserveInvocation(ctx, te.logger, te.metrics, llm.NewInvocationStats(te.modelID, llm.OperationEmbeddings, start, nil, &attempts, llm.Usage{}, err))
+		llm.EndSpan(span, llm.Usage{}, "", err)
+		return titan_embedding.Response{}, err
+	}
+
+	usage := llm.UsageFromMetadata(output.ResultMetadata)
+	reservation.Settle(usage)
+
+	llm.ObserveInvocation(ctx, te.logger, te.metrics, llm.NewInvocationStats(te.modelID, llm.OperationEmbeddings, start, &output.ResultMetadata, &attempts, usage, nil))
+
+	var resp titan_embedding.Response
+
+	err = json.Unmarshal(output.Body, &resp)
+
+	llm.EndSpan(span, usage, "", err)
+
+	if err != nil {
+		return titan_embedding.Response{}, err
+	}
+
+	return resp, nil
 }
@@ -2,8 +2,15 @@ package amazontitan
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
+	titan_embedding "github.com/abhirockzz/amazon-bedrock-go-inference-params/amazontitan/embedding"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +41,168 @@ func TestEmbedDocuments(t *testing.T) {
 	assert.Equal(t, 1536, len(result[0]))
 	assert.Equal(t, 1536, len(result[1]))
 }
+
+// lengthClient answers every embedding request with a vector holding the
+// length of the input text.
+type lengthClient struct {
+	llm.RuntimeClient
+	calls int
+}
+
+func (c *lengthClient) InvokeModel(_ context.Context, params *bedrockruntime.InvokeModelInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	c.calls++
+
+	var req titan_embedding.Request
+	if err := json.Unmarshal(params.Body, &req); err != nil {
+		return nil, err
+	}
+
+	return &bedrockruntime.InvokeModelOutput{Body: []byte(fmt.Sprintf(`{"embedding":[%d],"inputTextTokenCount":1}`, len(req.InputText)))}, nil
+}
+
+func TestEmbedDocumentsOneVectorPerText(t *testing.T) {
+
+	client := &lengthClient{}
+
+	titanEmbedder, err := New("us-east-1", llm.WithRuntimeClient(client))
+	assert.Nil(t, err)
+
+	// BatchSize no longer groups texts
+	titanEmbedder.BatchSize = 3
+	result, err := titanEmbedder.EmbedDocuments(context.Background(), []string{"a", "bb", "ccc", "dddd"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, [][]float32{{1}, {2}, {3}, {4}}, result)
+	assert.Equal(t, 4, client.calls)
+}
+
+func TestEmbedDocumentsOversizeSplit(t *testing.T) {
+
+	titanEmbedder, err := New("us-east-1")
+	assert.Nil(t, err)
+
+	titanEmbedder.Oversize = OversizeSplit
+	titanEmbedder.MaxChunkTokens = 4
+
+	texts := []string{"a rather long document that will be split into several chunks", "foo"}
+
+	result, err := titanEmbedder.EmbedDocuments(context.Background(), texts)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, 1536, len(result[0]))
+}
+
+func TestFitInputSplitLongTexts(t *testing.T) {
+
+	te := &TitanEmbedder{SplitLongTexts: true, MaxChunkTokens: 2}
+
+	chunks, err := te.fitInput(0, "one two six ten")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"one two ", "six ten"}, chunks)
+	assert.Equal(t, strconv.Itoa(int(OversizeSplit)), te.Parameters()["oversize"])
+}
+
+func TestSplitOnTokenBoundaries(t *testing.T) {
+
+	text := "the quick brown fox, jumps over the lazy dog"
+
+	chunks := splitOnTokenBoundaries(llm.TitanEstimator, text, 3)
+
+	assert.Equal(t, text, strings.Join(chunks, ""))
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, llm.TitanEstimator.CountTokens(chunk), 3)
+		assert.False(t, strings.HasPrefix(chunk, " "))
+	}
+
+	long := strings.Repeat("x", 30)
+	assert.Equal(t, []string{strings.Repeat("x", 12), strings.Repeat("x", 12), strings.Repeat("x", 6)}, splitOnTokenBoundaries(llm.TitanEstimator, long, 3))
+}
+
+func TestEmbedDocumentsOversizeError(t *testing.T) {
+
+	titanEmbedder, err := New("us-east-1")
+	assert.Nil(t, err)
+
+	titanEmbedder.Oversize = OversizeError
+	titanEmbedder.MaxChunkTokens = 4
+
+	_, err = titanEmbedder.EmbedDocuments(context.Background(), []string{"foo", "a rather long document that is over the limit"})
+	assert.ErrorIs(t, err, ErrInputTooLong)
+}
+
+func TestFitInputPassesThroughByDefault(t *testing.T) {
+
+	// about 7,000 Titan tokens of ordinary prose, which Titan accepts but the
+	// estimate puts over the limit
+	sentence := "The invoice, dated March 3rd, was paid on time; however, the shipment (order #4512) arrived late. "
+	text := strings.Repeat(sentence, 270)
+	assert.Greater(t, llm.TitanEstimator.CountTokens(text), defaultMaxChunkTokens)
+
+	te := &TitanEmbedder{}
+
+	kept, err := te.fitInput(0, text)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{text}, kept)
+}
+
+func TestFitInputTruncates(t *testing.T) {
+
+	var truncated []TruncatedInput
+
+	te := &TitanEmbedder{MaxChunkTokens: 3, OnTruncate: func(ti TruncatedInput) {
+		truncated = append(truncated, ti)
+	}}
+
+	te.Oversize = OversizeTruncateTail
+	kept, err := te.fitInput(2, "one two six ten red")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"one two six "}, kept)
+
+	te.Oversize = OversizeTruncateHead
+	kept, err = te.fitInput(5, "one two six ten red")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"six ten red"}, kept)
+
+	assert.Equal(t, []TruncatedInput{{Index: 2, EstimatedTokens: 5, KeptTokens: 3}, {Index: 5, EstimatedTokens: 5, KeptTokens: 3}}, truncated)
+
+	kept, err = te.fitInput(0, "one two")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"one two"}, kept)
+	assert.Equal(t, 2, len(truncated))
+}
+
+// wordCounter counts whitespace-separated words.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestFitInputUsesTokenCounter(t *testing.T) {
+
+	te, err := New("us-east-1", llm.WithTokenCounter(wordCounter{}))
+	assert.Nil(t, err)
+
+	te.Oversize = OversizeError
+	te.MaxChunkTokens = 2
+
+	// four tokens for the Titan estimator, two words
+	kept, err := te.fitInput(0, "aaaaaaaa bbbbbbbb")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"aaaaaaaa bbbbbbbb"}, kept)
+
+	_, err = te.fitInput(0, "one two three")
+	assert.ErrorIs(t, err, ErrInputTooLong)
+}
+
+func TestSplitOnTokenBoundariesCJK(t *testing.T) {
+
+	chunks := splitOnTokenBoundaries(llm.TitanEstimator, "東京都の天気は晴れです", 4)
+
+	assert.Equal(t, []string{"東京都の", "天気は晴", "れです"}, chunks)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, llm.TitanEstimator.CountTokens(chunk), 4)
+	}
+}
@@ -0,0 +1,143 @@
+package amazontitan
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+)
+
+// splitOnTokenBoundaries splits text into chunks of at most maxTokens tokens
+// each, as counted by counter. Chunks are only cut between pieces (see
+// tokenPieces), except when a single piece is longer than maxTokens on its
+// own.
+func splitOnTokenBoundaries(counter llm.TokenCounter, text string, maxTokens int) []string {
+
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentTokens = 0
+		}
+	}
+
+	for _, piece := range tokenPieces(text) {
+		tokens := counter.CountTokens(piece)
+
+		if tokens > maxTokens {
+			flush()
+			chunks = append(chunks, splitPiece(counter, piece, maxTokens)...)
+			continue
+		}
+
+		if currentTokens+tokens > maxTokens {
+			flush()
+		}
+
+		current.WriteString(piece)
+		currentTokens += tokens
+	}
+
+	flush()
+
+	return chunks
+}
+
+// tokenPieces splits text into pieces that each end on a token boundary: a run
+// of letters and digits, a single CJK character or a single other non-space
+// character, together with the whitespace that follows it.
+func tokenPieces(text string) []string {
+
+	var pieces []string
+	start := 0
+	inWord := false
+
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			inWord = false
+		case (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r):
+			if !inWord && i > start {
+				pieces = append(pieces, text[start:i])
+				start = i
+			}
+			inWord = true
+		default:
+			if i > start {
+				pieces = append(pieces, text[start:i])
+				start = i
+			}
+			inWord = false
+		}
+	}
+
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+
+	return pieces
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// splitPiece splits a piece that does not fit in maxTokens into parts that
+// do, cutting between characters.
+func splitPiece(counter llm.TokenCounter, piece string, maxTokens int) []string {
+
+	runes := []rune(piece)
+	var parts []string
+
+	for len(runes) > 0 {
+		rest := runes
+		n := longestFitting(len(rest), func(n int) bool {
+			return counter.CountTokens(string(rest[:n])) <= maxTokens
+		})
+		parts = append(parts, string(rest[:n]))
+		runes = rest[n:]
+	}
+
+	return parts
+}
+
+// longestFitting returns the largest n in [1, max] for which fits(n) is true,
+// assuming fits is true up to some n and false beyond it, and 1 if fits(1) is
+// false. It grows n exponentially and then bisects, so that long inputs are
+// not counted once per character.
+func longestFitting(max int, fits func(n int) bool) int {
+
+	lo := 1
+	if !fits(lo) {
+		return lo
+	}
+
+	hi := 0
+	for lo < max {
+		next := min(lo*2, max)
+		if !fits(next) {
+			hi = next
+			break
+		}
+		lo = next
+	}
+
+	if hi == 0 {
+		return lo
+	}
+
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo
+}
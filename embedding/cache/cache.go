@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tmc/langchaingo/embeddings"
+)
+
+// Describer is implemented by embedders that can report the model they use and
+// the parameters that influence the vectors they produce (TitanEmbedder does).
+// Both are part of the cache key, so changing a parameter bypasses the vectors
+// cached under the previous settings.
+type Describer interface {
+	ModelID() string
+	Parameters() map[string]string
+}
+
+// Store persists vectors by key.
+type Store interface {
+	Get(ctx context.Context, key string) ([]float32, bool, error)
+	Set(ctx context.Context, key string, vector []float32) error
+}
+
+type Embedder struct {
+	embedder  embeddings.Embedder
+	store     Store
+	modelID   string
+	normalize func(string) string
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var _ embeddings.Embedder = (*Embedder)(nil)
+
+var (
+	ErrMissingEmbedder = errors.New("missing embedder")
+	ErrMissingStore    = errors.New("missing store")
+	// ErrVectorCount is returned (wrapped) when the wrapped embedder does not
+	// return one vector per text.
+	ErrVectorCount = errors.New("embedder returned a vector count different from the text count")
+)
+
+type Option func(*Options)
+
+type Options struct {
+	// ModelID is used in the cache key when the wrapped embedder does not
+	// implement Describer.
+	ModelID   string
+	Normalize func(string) string
+}
+
+func WithModelID(modelID string) Option {
+	return func(o *Options) {
+		o.ModelID = modelID
+	}
+}
+
+// WithNormalizer replaces the function applied to a text before it is hashed.
+func WithNormalizer(normalize func(string) string) Option {
+	return func(o *Options) {
+		o.Normalize = normalize
+	}
+}
+
+// New wraps embedder so that vectors are looked up in store before the
+// embedder is called, and stored afterwards.
+func New(embedder embeddings.Embedder, store Store, options ...Option) (*Embedder, error) {
+
+	if embedder == nil {
+		return nil, ErrMissingEmbedder
+	}
+
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+
+	opts := &Options{Normalize: Normalize}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &Embedder{
+		embedder:  embedder,
+		store:     store,
+		modelID:   opts.ModelID,
+		normalize: opts.Normalize,
+	}, nil
+}
+
+// Normalize is the default normalizer: it unifies line endings and trims
+// leading and trailing white space.
+func Normalize(text string) string {
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+func (e *Embedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+
+	prefix := e.keyPrefix()
+
+	emb := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+
+	// texts not found in the store, deduplicated by key
+	var missingTexts []string
+	missingIndexes := map[string][]int{}
+
+	for i, text := range texts {
+		keys[i] = e.key(prefix, text)
+
+		if indexes, ok := missingIndexes[keys[i]]; ok {
+			missingIndexes[keys[i]] = append(indexes, i)
+			continue
+		}
+
+		vector, ok, err := e.store.Get(ctx, keys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			e.hits.Add(1)
+			emb[i] = vector
+			continue
+		}
+
+		e.misses.Add(1)
+		missingTexts = append(missingTexts, text)
+		missingIndexes[keys[i]] = []int{i}
+	}
+
+	if len(missingTexts) == 0 {
+		return emb, nil
+	}
+
+	vectors, err := e.embedder.EmbedDocuments(ctx, missingTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) != len(missingTexts) {
+		return nil, fmt.Errorf("%w: %d vectors for %d texts", ErrVectorCount, len(vectors), len(missingTexts))
+	}
+
+	for i, vector := range vectors {
+		key := e.key(prefix, missingTexts[i])
+
+		if err := e.store.Set(ctx, key, vector); err != nil {
+			return nil, err
+		}
+
+		for _, index := range missingIndexes[key] {
+			emb[index] = vector
+		}
+	}
+
+	return emb, nil
+}
+
+func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+
+	key := e.key(e.keyPrefix(), text)
+
+	vector, ok, err := e.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		e.hits.Add(1)
+		return vector, nil
+	}
+
+	e.misses.Add(1)
+
+	vector, err = e.embedder.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.store.Set(ctx, key, vector); err != nil {
+		return nil, err
+	}
+
+	return vector, nil
+}
+
+// Stats reports cache effectiveness since the Embedder was created.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+func (e *Embedder) Stats() Stats {
+	return Stats{Hits: e.hits.Load(), Misses: e.misses.Load()}
+}
+
+// keyPrefix identifies the model and parameters the wrapped embedder currently
+// uses. It is computed on every call so that parameter changes take effect
+// immediately.
+func (e *Embedder) keyPrefix() string {
+
+	modelID := e.modelID
+	var params map[string]string
+
+	if d, ok := e.embedder.(Describer); ok {
+		modelID = d.ModelID()
+		params = d.Parameters()
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(modelID)
+	for _, name := range names {
+		b.WriteString("\x00")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(params[name])
+	}
+
+	return b.String()
+}
+
+func (e *Embedder) key(prefix, text string) string {
+	h := sha256.New()
+	h.Write([]byte(prefix))
+	h.Write([]byte{0})
+	h.Write([]byte(e.normalize(text)))
+	return hex.EncodeToString(h.Sum(nil))
+}
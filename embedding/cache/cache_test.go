@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingEmbedder struct {
+	calls  int
+	texts  []string
+	params map[string]string
+}
+
+func (c *countingEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	c.calls++
+	c.texts = append(c.texts, texts...)
+
+	emb := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		emb = append(emb, []float32{float32(len(text)), 1})
+	}
+	return emb, nil
+}
+
+func (c *countingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	emb, err := c.EmbedDocuments(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return emb[0], nil
+}
+
+func (c *countingEmbedder) ModelID() string {
+	return "test-model"
+}
+
+func (c *countingEmbedder) Parameters() map[string]string {
+	return c.params
+}
+
+func TestEmbedDocuments(t *testing.T) {
+
+	underlying := &countingEmbedder{params: map[string]string{"dim": "2"}}
+
+	cached, err := New(underlying, NewMemoryStore(0))
+	assert.Nil(t, err)
+
+	result, err := cached.EmbedDocuments(context.Background(), []string{"foo", "barbaz", "foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float32{{3, 1}, {6, 1}, {3, 1}}, result)
+	assert.Equal(t, []string{"foo", "barbaz"}, underlying.texts)
+
+	result, err = cached.EmbedDocuments(context.Background(), []string{" foo ", "qux"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float32{{3, 1}, {3, 1}}, result)
+	assert.Equal(t, []string{"foo", "barbaz", "qux"}, underlying.texts)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 3}, cached.Stats())
+
+	// a parameter change must not reuse vectors cached under the old settings
+	underlying.params["dim"] = "4"
+
+	_, err = cached.EmbedQuery(context.Background(), "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "barbaz", "qux", "foo"}, underlying.texts)
+}
+
+// miscountingEmbedder returns a fixed number of vectors.
+type miscountingEmbedder struct {
+	countingEmbedder
+	n int
+}
+
+func (m *miscountingEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	return make([][]float32, m.n), nil
+}
+
+func TestEmbedDocumentsVectorCount(t *testing.T) {
+
+	for _, n := range []int{1, 3} {
+		cached, err := New(&miscountingEmbedder{n: n}, NewMemoryStore(0))
+		assert.Nil(t, err)
+
+		_, err = cached.EmbedDocuments(context.Background(), []string{"foo", "bar"})
+		assert.ErrorIs(t, err, ErrVectorCount)
+	}
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+
+	store := NewMemoryStore(0)
+	ctx := context.Background()
+
+	vector := []float32{1, 2}
+	assert.Nil(t, store.Set(ctx, "a", vector))
+	vector[0] = 9
+
+	got, _, _ := store.Get(ctx, "a")
+	assert.Equal(t, []float32{1, 2}, got)
+	got[1] = 9
+
+	got, _, _ = store.Get(ctx, "a")
+	assert.Equal(t, []float32{1, 2}, got)
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+
+	store := NewMemoryStore(2)
+	ctx := context.Background()
+
+	assert.Nil(t, store.Set(ctx, "a", []float32{1}))
+	assert.Nil(t, store.Set(ctx, "b", []float32{2}))
+
+	_, ok, _ := store.Get(ctx, "a")
+	assert.True(t, ok)
+
+	assert.Nil(t, store.Set(ctx, "c", []float32{3}))
+
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestFileStore(t *testing.T) {
+
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "abcdef")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, store.Set(ctx, "abcdef", []float32{0.5, -1.25, 3}))
+
+	vector, ok, err := store.Get(ctx, "abcdef")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []float32{0.5, -1.25, 3}, vector)
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/internal/store"
+)
+
+// FileStore is a Store that keeps one file per vector in a directory, so that
+// cached vectors survive process restarts. Vectors are written as little-endian
+// float32 values.
+type FileStore struct {
+	files *store.Files
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore creates a FileStore rooted at dir, creating dir if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	files, err := store.NewFiles(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{files: files}, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) ([]float32, bool, error) {
+
+	data, ok, err := s.files.Read(key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+
+	if len(data)%4 != 0 {
+		// a partially written or foreign file, treat it as a miss
+		return nil, false, nil
+	}
+
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+
+	return vector, true, nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, vector []float32) error {
+
+	data := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+
+	return s.files.Write(key, data)
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"slices"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/internal/store"
+)
+
+// MemoryStore is an in-memory Store that evicts the least recently used vector
+// once it holds more than its capacity. It keeps and hands out copies of
+// vectors, so callers may modify the vectors they pass and receive.
+type MemoryStore struct {
+	lru *store.LRU[[]float32]
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore holding at most capacity vectors. A
+// capacity of zero or less means unbounded.
+func NewMemoryStore(capacity int) *MemoryStore {
+	lru := store.NewLRU[[]float32](capacity)
+	lru.Clone = slices.Clone[[]float32]
+	return &MemoryStore{lru: lru}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]float32, bool, error) {
+	vector, ok := s.lru.Get(key)
+	return vector, ok, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, vector []float32) error {
+	s.lru.Set(key, vector)
+	return nil
+}
+
+// Len returns the number of vectors currently held.
+func (s *MemoryStore) Len() int {
+	return s.lru.Len()
+}
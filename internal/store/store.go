@@ -0,0 +1,177 @@
+// Package store provides the storage shared by the LLM response cache and the
+// embedding cache: an in-memory LRU map and a directory of files, both keyed
+// by string.
+package store
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LRU maps keys to values in memory and evicts the least recently used value
+// once it holds more than its capacity.
+type LRU[V any] struct {
+	// Clone, if not nil, copies values as they are stored and read, so that
+	// callers cannot modify the values held.
+	Clone func(V) V
+	// Valid, if not nil, reports whether a value can still be used. Invalid
+	// values are dropped when they are read.
+	Valid func(V) bool
+
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// NewLRU creates an LRU holding at most capacity values. A capacity of zero
+// or less means unbounded.
+func NewLRU[V any](capacity int) *LRU[V] {
+	return &LRU[V]{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (l *LRU[V]) Get(key string) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var zero V
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return zero, false
+	}
+
+	value := elem.Value.(*lruEntry[V]).value
+	if l.Valid != nil && !l.Valid(value) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return zero, false
+	}
+
+	l.order.MoveToFront(elem)
+
+	if l.Clone != nil {
+		value = l.Clone(value)
+	}
+	return value, true
+}
+
+func (l *LRU[V]) Set(key string, value V) {
+
+	if l.Clone != nil {
+		value = l.Clone(value)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		elem.Value.(*lruEntry[V]).value = value
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry[V]{key: key, value: value})
+
+	if l.capacity > 0 && l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Len returns the number of values currently held.
+func (l *LRU[V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
+
+// Files keeps one file per key in a directory. Files are sharded by the first
+// two characters of the key to keep directories small; keys are expected to
+// be hashes.
+type Files struct {
+	dir string
+	ext string
+}
+
+// NewFiles creates a Files rooted at dir, creating dir if needed. ext is
+// appended to the file names, for example ".json".
+func NewFiles(dir, ext string) (*Files, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Files{dir: dir, ext: ext}, nil
+}
+
+// Read returns the contents of the file of key, and false if there is none.
+func (f *Files) Read(key string) ([]byte, bool, error) {
+
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Write replaces the file of key with data. The data is written to a
+// temporary file first, so that readers never see partial contents.
+func (f *Files) Write(key string, data []byte) error {
+
+	path := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Remove removes the file of key, if any.
+func (f *Files) Remove(key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *Files) path(key string) string {
+	if len(key) < 3 {
+		return filepath.Join(f.dir, key+f.ext)
+	}
+	return filepath.Join(f.dir, key[:2], key[2:]+f.ext)
+}
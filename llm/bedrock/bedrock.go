@@ -0,0 +1,50 @@
+// Package bedrock builds the LLM implementation matching a Bedrock model ID, so
+// that the model can be chosen by configuration rather than by code.
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/claude"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/cohere"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/llama"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var (
+	ErrMissingModel        = errors.New("empty model ID")
+	ErrUnsupportedProvider = errors.New("unsupported model provider")
+)
+
+// New returns the llms.LLM for modelID, which may be any identifier accepted by
+// llm.BaseModelID. For identifiers that do not name their model, such as
+// provisioned throughput ARNs, pass llm.WithBaseModel. options are passed on to
+// the constructor of the model package; llm.WithModel is not needed.
+func New(region, modelID string, options ...llm.ConfigOption) (llms.LLM, error) {
+
+	if modelID == "" {
+		return nil, ErrMissingModel
+	}
+
+	options = append(options[:len(options):len(options)], llm.WithModel(modelID))
+
+	opts := &llm.ConfigOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	switch provider := llm.ProviderOf(llm.ResolveBaseModel(modelID, opts)); provider {
+	case llm.ProviderAnthropic:
+		return claude.New(region, options...)
+	case llm.ProviderMeta:
+		return llama.New(region, options...)
+	case llm.ProviderCohere:
+		return cohere.New(region, options...)
+	case "":
+		return nil, fmt.Errorf("%w: cannot tell the provider of model %s, pass llm.WithBaseModel with the model it is based on", ErrUnsupportedProvider, modelID)
+	default:
+		return nil, fmt.Errorf("%w: %q (model %s)", ErrUnsupportedProvider, provider, modelID)
+	}
+}
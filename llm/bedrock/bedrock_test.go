@@ -0,0 +1,49 @@
+package bedrock
+
+import (
+	"testing"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/claude"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/cohere"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/llama"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+
+	client := llm.WithBedrockRuntimeClient(bedrockruntime.New(bedrockruntime.Options{Region: "us-east-1"}))
+
+	model, err := New("us-east-1", llm.ModelClaudeV2, client)
+	assert.NoError(t, err)
+	assert.IsType(t, &claude.LLM{}, model)
+
+	model, err = New("us-east-1", "arn:aws:bedrock:us-east-1::foundation-model/meta.llama2-70b-chat-v1", client)
+	assert.NoError(t, err)
+	assert.IsType(t, &llama.LLM{}, model)
+
+	model, err = New("us-east-1", llm.ModelCohereCommandLight, client)
+	assert.NoError(t, err)
+	assert.IsType(t, &cohere.LLM{}, model)
+
+	model, err = New("us-east-1", "us.anthropic.claude-v2", client)
+	assert.NoError(t, err)
+	assert.IsType(t, &claude.LLM{}, model)
+
+	provisioned := "arn:aws:bedrock:us-east-1:123456789012:provisioned-model/abcdef123456"
+
+	_, err = New("us-east-1", provisioned, client)
+	assert.ErrorIs(t, err, ErrUnsupportedProvider)
+	assert.ErrorContains(t, err, "llm.WithBaseModel")
+
+	model, err = New("us-east-1", provisioned, client, llm.WithBaseModel(llm.ModelLlama2Chat13B))
+	assert.NoError(t, err)
+	assert.IsType(t, &llama.LLM{}, model)
+
+	_, err = New("us-east-1", "ai21.j2-ultra-v1", client)
+	assert.ErrorIs(t, err, ErrUnsupportedProvider)
+
+	_, err = New("us-east-1", "", client)
+	assert.ErrorIs(t, err, ErrMissingModel)
+}
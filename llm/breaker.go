@@ -0,0 +1,377 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/tmc/langchaingo/callbacks"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+	defaultBreakerHalfOpenProbes   = 1
+)
+
+// ErrCircuitOpen is returned (wrapped) without invoking the model while the
+// circuit breaker of a model is open. It counts as a failover error.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitStateChange describes a transition of the circuit of one model.
+type CircuitStateChange struct {
+	// Name identifies the circuit breaker, usually by region.
+	Name    string
+	ModelID string
+	From    CircuitState
+	To      CircuitState
+}
+
+// CircuitBreakerHandler is implemented by callbacks handlers that want to be
+// told about circuit breaker state changes.
+type CircuitBreakerHandler interface {
+	HandleCircuitStateChange(ctx context.Context, change CircuitStateChange)
+}
+
+// CircuitMetricsRecorder is implemented by a MetricsRecorder that records
+// circuit breaker state changes (the metrics package does).
+type CircuitMetricsRecorder interface {
+	RecordCircuitStateChange(ctx context.Context, change CircuitStateChange)
+}
+
+// CircuitBreaker is a RuntimeClient that stops invoking a model that keeps
+// failing. Each model ID has its own circuit:
+//
+//   - closed: invocations go through; after the failure threshold of
+//     consecutive failures the circuit opens
+//   - open: invocations fail immediately with ErrCircuitOpen; after the
+//     cool-down the circuit becomes half-open
+//   - half-open: one invocation at a time goes through as a probe; enough
+//     successful probes close the circuit, a failed one opens it again
+//
+// Only errors for which the failure classifier returns true (IsFailoverError by
+// default) count as failures. For streams, only the invocation itself is
+// observed, not errors in the response stream.
+type CircuitBreaker struct {
+	client           RuntimeClient
+	name             string
+	failureThreshold int
+	cooldown         time.Duration
+	halfOpenProbes   int
+	isFailure        func(error) bool
+	logger           *slog.Logger
+	handlers         []CircuitBreakerHandler
+	metrics          []CircuitMetricsRecorder
+	now              func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	probing   bool
+}
+
+var _ RuntimeClient = (*CircuitBreaker)(nil)
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithBreakerName sets the name reported in state changes, for example the
+// region of the client.
+func WithBreakerName(name string) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.name = name
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failures that opens a
+// circuit (5 by default).
+func WithFailureThreshold(n int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.failureThreshold = n
+	}
+}
+
+// WithBreakerCooldown sets how long a circuit stays open before probing the
+// model again (30 seconds by default).
+func WithBreakerCooldown(d time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.cooldown = d
+	}
+}
+
+// WithHalfOpenProbes sets the number of successful probes that closes a
+// half-open circuit (1 by default).
+func WithHalfOpenProbes(n int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.halfOpenProbes = n
+	}
+}
+
+// WithFailureClassifier sets the errors that count as failures.
+func WithFailureClassifier(isFailure func(error) bool) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.isFailure = isFailure
+	}
+}
+
+// WithBreakerLogger logs state changes at warning level.
+func WithBreakerLogger(logger *slog.Logger) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		if logger != nil {
+			b.logger = logger
+		}
+	}
+}
+
+// WithBreakerCallbacks reports state changes to handler if it implements
+// CircuitBreakerHandler. The CallbacksHandler of the LLM making an invocation
+// is told about the state changes it causes without this option; use it for
+// other handlers, or to be told about changes caused by any LLM.
+func WithBreakerCallbacks(handler callbacks.Handler) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		if h, ok := handler.(CircuitBreakerHandler); ok {
+			b.handlers = append(b.handlers, h)
+		}
+	}
+}
+
+// WithBreakerMetrics reports state changes to metrics if it implements
+// CircuitMetricsRecorder.
+func WithBreakerMetrics(metrics MetricsRecorder) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		if m, ok := metrics.(CircuitMetricsRecorder); ok {
+			b.metrics = append(b.metrics, m)
+		}
+	}
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that invokes models with client.
+func NewCircuitBreaker(client RuntimeClient, options ...CircuitBreakerOption) *CircuitBreaker {
+
+	b := &CircuitBreaker{
+		client:           client,
+		failureThreshold: defaultBreakerFailureThreshold,
+		cooldown:         defaultBreakerCooldown,
+		halfOpenProbes:   defaultBreakerHalfOpenProbes,
+		isFailure:        IsFailoverError,
+		logger:           NopLogger(),
+		now:              time.Now,
+		circuits:         map[string]*circuit{},
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+
+	return b
+}
+
+func (b *CircuitBreaker) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	return invokeWithBreaker(ctx, b, aws.ToString(params.ModelId), func() (*bedrockruntime.InvokeModelOutput, error) {
+		return b.client.InvokeModel(ctx, params, optFns...)
+	})
+}
+
+func (b *CircuitBreaker) InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+	return invokeWithBreaker(ctx, b, aws.ToString(params.ModelId), func() (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+		return b.client.InvokeModelWithResponseStream(ctx, params, optFns...)
+	})
+}
+
+// State returns the current state of the circuit of modelID.
+func (b *CircuitBreaker) State(modelID string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[modelID]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && !b.now().Before(c.openedAt.Add(b.cooldown)) {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+func invokeWithBreaker[T any](ctx context.Context, b *CircuitBreaker, modelID string, call func() (T, error)) (T, error) {
+
+	var zero T
+
+	if err := b.acquire(ctx, modelID); err != nil {
+		return zero, err
+	}
+
+	out, err := call()
+	b.release(ctx, modelID, err)
+
+	return out, err
+}
+
+// acquire returns ErrCircuitOpen if the circuit of modelID does not let an
+// invocation through.
+func (b *CircuitBreaker) acquire(ctx context.Context, modelID string) error {
+	var changes []CircuitStateChange
+	defer func() { b.emit(ctx, changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(modelID)
+
+	if c.state == CircuitOpen && !b.now().Before(c.openedAt.Add(b.cooldown)) {
+		changes = append(changes, b.transition(modelID, c, CircuitHalfOpen))
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		return fmt.Errorf("%w: %s", ErrCircuitOpen, modelID)
+	case CircuitHalfOpen:
+		if c.probing {
+			return fmt.Errorf("%w: %s (probing)", ErrCircuitOpen, modelID)
+		}
+		c.probing = true
+	}
+
+	return nil
+}
+
+func (b *CircuitBreaker) release(ctx context.Context, modelID string, err error) {
+	var changes []CircuitStateChange
+	defer func() { b.emit(ctx, changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(modelID)
+	failed := err != nil && b.isFailure(err)
+
+	switch c.state {
+	case CircuitHalfOpen:
+		c.probing = false
+		if failed {
+			changes = append(changes, b.transition(modelID, c, CircuitOpen))
+			return
+		}
+		if err == nil {
+			c.successes++
+			if c.successes >= b.halfOpenProbes {
+				changes = append(changes, b.transition(modelID, c, CircuitClosed))
+			}
+		}
+
+	case CircuitClosed:
+		if !failed {
+			if err == nil {
+				c.failures = 0
+			}
+			return
+		}
+		c.failures++
+		if c.failures >= b.failureThreshold {
+			changes = append(changes, b.transition(modelID, c, CircuitOpen))
+		}
+	}
+}
+
+func (b *CircuitBreaker) circuit(modelID string) *circuit {
+	c, ok := b.circuits[modelID]
+	if !ok {
+		c = &circuit{}
+		b.circuits[modelID] = c
+	}
+	return c
+}
+
+// transition must be called with b.mu held. The returned change must be
+// emitted once b.mu is released.
+func (b *CircuitBreaker) transition(modelID string, c *circuit, to CircuitState) CircuitStateChange {
+
+	change := CircuitStateChange{Name: b.name, ModelID: modelID, From: c.state, To: to}
+
+	c.state = to
+	c.failures = 0
+	c.successes = 0
+	if to == CircuitOpen {
+		c.openedAt = b.now()
+	}
+
+	return change
+}
+
+func (b *CircuitBreaker) emit(ctx context.Context, changes []CircuitStateChange) {
+	for _, change := range changes {
+
+		b.logger.WarnContext(ctx, "circuit breaker state change",
+			slog.String("breaker", change.Name),
+			slog.String("model_id", change.ModelID),
+			slog.String("from", change.From.String()),
+			slog.String("to", change.To.String()))
+
+		for _, h := range b.handlers {
+			h.HandleCircuitStateChange(ctx, change)
+		}
+		if h, ok := b.callerHandler(ctx); ok {
+			h.HandleCircuitStateChange(ctx, change)
+		}
+		for _, m := range b.metrics {
+			m.RecordCircuitStateChange(ctx, change)
+		}
+	}
+}
+
+// callerHandler returns the handler of the LLM making the invocation, unless
+// it was also passed with WithBreakerCallbacks.
+func (b *CircuitBreaker) callerHandler(ctx context.Context) (CircuitBreakerHandler, bool) {
+
+	handler, ok := CallbacksFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	h, ok := handler.(CircuitBreakerHandler)
+	if !ok {
+		return nil, false
+	}
+
+	if reflect.TypeOf(h).Comparable() {
+		for _, registered := range b.handlers {
+			if reflect.TypeOf(registered) == reflect.TypeOf(h) && registered == h {
+				return nil, false
+			}
+		}
+	}
+
+	return h, true
+}
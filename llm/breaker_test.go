@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+
+	endpoint := newStandIn(t, http.StatusServiceUnavailable)
+	handler := &recordingHandler{}
+
+	now := time.Now()
+	b := NewCircuitBreaker(endpoint.client(),
+		WithBreakerName("us-east-1"),
+		WithFailureThreshold(2),
+		WithBreakerCooldown(time.Minute),
+		WithBreakerCallbacks(handler))
+	b.now = func() time.Time { return now }
+
+	invoke := func(modelID string) error {
+		_, err := b.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{ModelId: aws.String(modelID), Body: []byte(`{}`)})
+		return err
+	}
+
+	// consecutive failures open the circuit, which then fails fast
+	assert.Error(t, invoke(ModelClaudeV2))
+	assert.Equal(t, CircuitClosed, b.State(ModelClaudeV2))
+	assert.Error(t, invoke(ModelClaudeV2))
+	assert.Equal(t, CircuitOpen, b.State(ModelClaudeV2))
+
+	err := invoke(ModelClaudeV2)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.True(t, IsFailoverError(err))
+	assert.Equal(t, int32(2), endpoint.calls.Load())
+
+	// other models have their own circuit
+	assert.NotErrorIs(t, invoke(ModelClaudeV21), ErrCircuitOpen)
+	assert.Equal(t, CircuitClosed, b.State(ModelClaudeV21))
+
+	// a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, b.State(ModelClaudeV2))
+	assert.NotErrorIs(t, invoke(ModelClaudeV2), ErrCircuitOpen)
+	assert.Equal(t, CircuitOpen, b.State(ModelClaudeV2))
+	assert.ErrorIs(t, invoke(ModelClaudeV2), ErrCircuitOpen)
+
+	// a successful probe closes it
+	endpoint.status.Store(http.StatusOK)
+	now = now.Add(time.Minute)
+	assert.NoError(t, invoke(ModelClaudeV2))
+	assert.Equal(t, CircuitClosed, b.State(ModelClaudeV2))
+
+	var states []CircuitState
+	for _, change := range handler.circuit {
+		assert.Equal(t, "us-east-1", change.Name)
+		assert.Equal(t, ModelClaudeV2, change.ModelID)
+		states = append(states, change.To)
+	}
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, states)
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+
+	endpoint := newStandIn(t, http.StatusBadRequest)
+
+	b := NewCircuitBreaker(endpoint.client(), WithFailureThreshold(1))
+
+	for i := 0; i < 3; i++ {
+		_, err := b.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)})
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+
+	assert.Equal(t, CircuitClosed, b.State(ModelClaudeV2))
+	assert.Equal(t, int32(3), endpoint.calls.Load())
+}
+
+func TestCircuitBreakerCallerHandler(t *testing.T) {
+
+	endpoint := newStandIn(t, http.StatusServiceUnavailable)
+	caller := &recordingHandler{}
+
+	b := NewCircuitBreaker(endpoint.client(), WithFailureThreshold(1))
+
+	ctx := ContextWithCallbacks(context.Background(), caller)
+	_, err := b.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)})
+	assert.Error(t, err)
+	assert.Len(t, caller.circuit, 1)
+	assert.Equal(t, CircuitOpen, caller.circuit[0].To)
+
+	// a handler passed both ways is told once
+	registered := &recordingHandler{}
+	b = NewCircuitBreaker(endpoint.client(), WithFailureThreshold(1), WithBreakerCallbacks(registered))
+
+	ctx = ContextWithCallbacks(context.Background(), registered)
+	_, err = b.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)})
+	assert.Error(t, err)
+	assert.Len(t, registered.circuit, 1)
+}
@@ -0,0 +1,353 @@
+// Package cache provides an llms.LLM that stores responses and answers
+// identical calls from its store instead of invoking the model again.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// GenerationInfoCacheHit is set to true in the GenerationInfo of generations
+// answered from the store.
+const GenerationInfoCacheHit = "cache_hit"
+
+var (
+	ErrMissingLLM    = errors.New("missing llm")
+	ErrMissingStore  = errors.New("missing store")
+	ErrEmptyResponse = errors.New("empty response")
+)
+
+// Renderer is implemented by LLMs that can report their model and render the
+// request body for a prompt (the claude, llama and cohere LLMs do). The cache
+// key is then the model ID and the exact payload sent to Bedrock, so that
+// changes to prompt formatting are not answered from stale entries.
+type Renderer interface {
+	ModelID() string
+	RenderRequest(prompt string, options ...llms.CallOption) ([]byte, error)
+}
+
+// Entry is a cached response.
+type Entry struct {
+	Text       string    `json:"text"`
+	StopReason string    `json:"stop_reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// ExpiresAt is zero for entries that do not expire.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e Entry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Store persists entries by key.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, bool, error)
+	Set(ctx context.Context, key string, entry Entry) error
+}
+
+type LLM struct {
+	llm            llms.LLM
+	store          Store
+	modelID        string
+	ttl            time.Duration
+	maxTemperature float64
+	replayChunk    int
+	now            func() time.Time
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	skipped atomic.Int64
+}
+
+var _ llms.LLM = (*LLM)(nil)
+
+type Option func(*Options)
+
+type Options struct {
+	// ModelID is used in the cache key when the wrapped LLM does not implement
+	// Renderer.
+	ModelID string
+	// TTL is how long entries are used. Zero means forever.
+	TTL time.Duration
+	// MaxTemperature is the highest temperature of cached calls. Calls with a
+	// higher temperature are passed through, because their responses are
+	// meant to vary.
+	MaxTemperature float64
+	// ReplayChunkSize is the number of characters passed to the streaming
+	// function at a time when a cached response is replayed. Zero replays the
+	// whole response at once.
+	ReplayChunkSize int
+}
+
+func WithModelID(modelID string) Option {
+	return func(o *Options) {
+		o.ModelID = modelID
+	}
+}
+
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithMaxTemperature caches calls with a temperature of up to t. By default
+// only calls with a temperature of zero are cached.
+func WithMaxTemperature(t float64) Option {
+	return func(o *Options) {
+		o.MaxTemperature = t
+	}
+}
+
+func WithReplayChunkSize(n int) Option {
+	return func(o *Options) {
+		o.ReplayChunkSize = n
+	}
+}
+
+// New wraps llm so that responses are looked up in store before llm is
+// called, and stored afterwards.
+func New(llm llms.LLM, store Store, options ...Option) (*LLM, error) {
+
+	if llm == nil {
+		return nil, ErrMissingLLM
+	}
+
+	if store == nil {
+		return nil, ErrMissingStore
+	}
+
+	opts := &Options{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &LLM{
+		llm:            llm,
+		store:          store,
+		modelID:        opts.ModelID,
+		ttl:            opts.TTL,
+		maxTemperature: opts.MaxTemperature,
+		replayChunk:    opts.ReplayChunkSize,
+		now:            time.Now,
+	}, nil
+}
+
+func (c *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	r, err := c.Generate(ctx, []string{prompt}, options...)
+	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
+		return "", err
+	}
+	if len(r) == 0 {
+		return "", ErrEmptyResponse
+	}
+	return r[0].Text, nil
+}
+
+// Generate answers every prompt from the store if possible, and otherwise with
+// the wrapped LLM. Cached responses are replayed through the streaming
+// function, if any.
+func (c *LLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	generations := make([]*llms.Generation, 0, len(prompts))
+
+	for _, prompt := range prompts {
+		g, err := c.generate(ctx, prompt, opts, options)
+		if g != nil {
+			generations = append(generations, g)
+		}
+		if err != nil {
+			return generations, err
+		}
+	}
+
+	return generations, nil
+}
+
+func (c *LLM) generate(ctx context.Context, prompt string, opts *llms.CallOptions, options []llms.CallOption) (*llms.Generation, error) {
+
+	key, ok := c.key(prompt, opts, options)
+	if !ok {
+		c.skipped.Add(1)
+		return generateOne(ctx, c.llm, prompt, options)
+	}
+
+	entry, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && !entry.expired(c.now()) {
+		c.hits.Add(1)
+
+		if opts.StreamingFunc != nil {
+			if err := replay(ctx, entry.Text, c.replayChunk, opts.StreamingFunc); err != nil {
+				return nil, err
+			}
+		}
+
+		return &llms.Generation{
+			Text:           entry.Text,
+			StopReason:     entry.StopReason,
+			GenerationInfo: map[string]any{GenerationInfoCacheHit: true},
+		}, nil
+	}
+
+	c.misses.Add(1)
+
+	g, err := generateOne(ctx, c.llm, prompt, options)
+	if err != nil {
+		return g, err
+	}
+
+	entry = Entry{Text: g.Text, StopReason: g.StopReason, CreatedAt: c.now()}
+	if c.ttl > 0 {
+		entry.ExpiresAt = entry.CreatedAt.Add(c.ttl)
+	}
+
+	if err := c.store.Set(ctx, key, entry); err != nil {
+		return g, err
+	}
+
+	return g, nil
+}
+
+// generateOne generates the response to a single prompt with llm.
+func generateOne(ctx context.Context, llm llms.LLM, prompt string, options []llms.CallOption) (*llms.Generation, error) {
+
+	generations, err := llm.Generate(ctx, []string{prompt}, options...)
+	if len(generations) == 0 {
+		if err == nil {
+			err = ErrEmptyResponse
+		}
+		return nil, err
+	}
+
+	return generations[0], err
+}
+
+// replay passes text to fn in chunks of chunkSize characters.
+func replay(ctx context.Context, text string, chunkSize int, fn func(ctx context.Context, chunk []byte) error) error {
+
+	if chunkSize <= 0 {
+		return fn(ctx, []byte(text))
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		n := min(chunkSize, len(runes))
+		if err := fn(ctx, []byte(string(runes[:n]))); err != nil {
+			return err
+		}
+		runes = runes[n:]
+	}
+
+	return nil
+}
+
+func (c *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, c, prompts, options...)
+}
+
+// Stats reports cache effectiveness since the LLM was created. Skipped counts
+// calls that were not cached because of their temperature.
+type Stats struct {
+	Hits    int64
+	Misses  int64
+	Skipped int64
+}
+
+func (c *LLM) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Skipped: c.skipped.Load()}
+}
+
+// keyOptions are the call options that can influence a response.
+type keyOptions struct {
+	Model                string                    `json:"model,omitempty"`
+	MaxTokens            int                       `json:"max_tokens,omitempty"`
+	Temperature          float64                   `json:"temperature,omitempty"`
+	StopWords            []string                  `json:"stop_words,omitempty"`
+	TopK                 int                       `json:"top_k,omitempty"`
+	TopP                 float64                   `json:"top_p,omitempty"`
+	Seed                 int                       `json:"seed,omitempty"`
+	MinLength            int                       `json:"min_length,omitempty"`
+	MaxLength            int                       `json:"max_length,omitempty"`
+	N                    int                       `json:"n,omitempty"`
+	RepetitionPenalty    float64                   `json:"repetition_penalty,omitempty"`
+	FrequencyPenalty     float64                   `json:"frequency_penalty,omitempty"`
+	PresencePenalty      float64                   `json:"presence_penalty,omitempty"`
+	Functions            []llms.FunctionDefinition `json:"functions,omitempty"`
+	FunctionCallBehavior llms.FunctionCallBehavior `json:"function_call,omitempty"`
+}
+
+// key returns the cache key of prompt, and false if the call must not be
+// cached.
+func (c *LLM) key(prompt string, opts *llms.CallOptions, options []llms.CallOption) (string, bool) {
+
+	if opts.Temperature > c.maxTemperature {
+		return "", false
+	}
+
+	modelID := c.modelID
+	payload := []byte(prompt)
+
+	if r, ok := c.llm.(Renderer); ok {
+		rendered, err := r.RenderRequest(prompt, options...)
+		if err != nil {
+			// the LLM fails the same way, there is nothing to cache
+			return "", false
+		}
+		modelID = r.ModelID()
+		payload = rendered
+	}
+
+	params, err := optionsKey(opts)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(modelID))
+	h.Write([]byte{0})
+	h.Write(payload)
+	h.Write([]byte{0})
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// optionsKey encodes the call options that can influence a response.
+func optionsKey(opts *llms.CallOptions) ([]byte, error) {
+	return json.Marshal(keyOptions{
+		Model:                opts.Model,
+		MaxTokens:            opts.MaxTokens,
+		Temperature:          opts.Temperature,
+		StopWords:            opts.StopWords,
+		TopK:                 opts.TopK,
+		TopP:                 opts.TopP,
+		Seed:                 opts.Seed,
+		MinLength:            opts.MinLength,
+		MaxLength:            opts.MaxLength,
+		N:                    opts.N,
+		RepetitionPenalty:    opts.RepetitionPenalty,
+		FrequencyPenalty:     opts.FrequencyPenalty,
+		PresencePenalty:      opts.PresencePenalty,
+		Functions:            opts.Functions,
+		FunctionCallBehavior: opts.FunctionCallBehavior,
+	})
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type countingLLM struct {
+	calls   []string
+	format  string
+	modelID string
+}
+
+func (c *countingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	g, err := c.Generate(ctx, []string{prompt}, options...)
+	if err != nil {
+		return "", err
+	}
+	return g[0].Text, nil
+}
+
+func (c *countingLLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	c.calls = append(c.calls, prompts[0])
+	text := strings.ToUpper(prompts[0])
+
+	if opts.StreamingFunc != nil {
+		if err := opts.StreamingFunc(ctx, []byte(text)); err != nil {
+			return nil, err
+		}
+	}
+
+	return []*llms.Generation{{Text: text, StopReason: "stop"}}, nil
+}
+
+func (c *countingLLM) ModelID() string {
+	return c.modelID
+}
+
+func (c *countingLLM) RenderRequest(prompt string, options ...llms.CallOption) ([]byte, error) {
+	return []byte(fmt.Sprintf(c.format, prompt)), nil
+}
+
+func TestGenerate(t *testing.T) {
+
+	underlying := &countingLLM{format: "Human: %s", modelID: "anthropic.claude-v2"}
+
+	cached, err := New(underlying, NewMemoryStore(0))
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	g, err := cached.Generate(ctx, []string{"foo", "bar"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FOO", g[0].Text)
+	assert.Equal(t, "BAR", g[1].Text)
+
+	g, err = cached.Generate(ctx, []string{"foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FOO", g[0].Text)
+	assert.Equal(t, "stop", g[0].StopReason)
+	assert.Equal(t, true, g[0].GenerationInfo[GenerationInfoCacheHit])
+
+	// different call options and prompt formats are different keys
+	_, err = cached.Call(ctx, "foo", llms.WithMaxTokens(10))
+	assert.Nil(t, err)
+
+	underlying.format = "\n\nHuman: %s"
+	_, err = cached.Call(ctx, "foo")
+	assert.Nil(t, err)
+
+	// high temperatures are not cached
+	_, err = cached.Call(ctx, "bar", llms.WithTemperature(0.7))
+	assert.Nil(t, err)
+	_, err = cached.Call(ctx, "bar", llms.WithTemperature(0.7))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"foo", "bar", "foo", "foo", "bar", "bar"}, underlying.calls)
+	assert.Equal(t, Stats{Hits: 1, Misses: 4, Skipped: 2}, cached.Stats())
+}
+
+func TestGenerateStreaming(t *testing.T) {
+
+	underlying := &countingLLM{format: "%s"}
+
+	cached, err := New(underlying, NewMemoryStore(0), WithModelID("test-model"), WithReplayChunkSize(2))
+	assert.Nil(t, err)
+
+	var chunks []string
+	stream := llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+
+	text, err := cached.Call(context.Background(), "héllo", stream)
+	assert.Nil(t, err)
+	assert.Equal(t, "HÉLLO", text)
+
+	text, err = cached.Call(context.Background(), "héllo", stream)
+	assert.Nil(t, err)
+	assert.Equal(t, "HÉLLO", text)
+
+	assert.Equal(t, []string{"HÉLLO", "HÉ", "LL", "O"}, chunks)
+	assert.Len(t, underlying.calls, 1)
+}
+
+func TestTTL(t *testing.T) {
+
+	underlying := &countingLLM{format: "%s"}
+
+	cached, err := New(underlying, NewMemoryStore(0), WithTTL(time.Minute))
+	assert.Nil(t, err)
+
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	_, err = cached.Call(ctx, "foo")
+	assert.Nil(t, err)
+	_, err = cached.Call(ctx, "foo")
+	assert.Nil(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = cached.Call(ctx, "foo")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"foo", "foo"}, underlying.calls)
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+
+	store := NewMemoryStore(2)
+	ctx := context.Background()
+
+	assert.Nil(t, store.Set(ctx, "a", Entry{Text: "1"}))
+	assert.Nil(t, store.Set(ctx, "b", Entry{Text: "2"}))
+
+	_, ok, _ := store.Get(ctx, "a")
+	assert.True(t, ok)
+
+	assert.Nil(t, store.Set(ctx, "c", Entry{Text: "3"}))
+
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, store.Len())
+
+	assert.Nil(t, store.Set(ctx, "d", Entry{Text: "4", ExpiresAt: time.Now().Add(-time.Second)}))
+	_, ok, _ = store.Get(ctx, "d")
+	assert.False(t, ok)
+}
+
+func TestFileStore(t *testing.T) {
+
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "abcdef")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Nil(t, store.Set(ctx, "abcdef", Entry{Text: "hello", StopReason: "stop_sequence", CreatedAt: created}))
+
+	entry, ok, err := store.Get(ctx, "abcdef")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Entry{Text: "hello", StopReason: "stop_sequence", CreatedAt: created}, entry)
+}
+
+// tableEmbedder embeds texts with the vectors in its table.
+type tableEmbedder map[string][]float32
+
+func (e tableEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	emb := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		emb = append(emb, e[text])
+	}
+	return emb, nil
+}
+
+func (e tableEmbedder) EmbedQuery(_ context.Context, text string) ([]float32, error) {
+	return e[text], nil
+}
+
+func TestSemanticLLM(t *testing.T) {
+
+	embedder := tableEmbedder{
+		"how do I reset my password?":     {1, 0, 0},
+		"how can I reset my password?":    {0.98, 0.2, 0},
+		"how do I delete my account?":     {0.5, 0.8, 0.3},
+		"what are your opening hours?":    {0, 0, 1},
+		"when are you open?":              {0, 0.1, 0.99},
+		"how do i reset my password, pls": {0.9, 0.3, 0.3},
+	}
+
+	underlying := &countingLLM{modelID: "anthropic.claude-v2"}
+	index := NewSemanticIndex(0)
+
+	cached, err := NewSemantic(underlying, embedder, index, WithSimilarityThreshold(0.95))
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	text, err := cached.Call(ctx, "how do I reset my password?")
+	assert.Nil(t, err)
+	assert.Equal(t, "HOW DO I RESET MY PASSWORD?", text)
+
+	g, err := cached.Generate(ctx, []string{"how can I reset my password?"})
+	assert.Nil(t, err)
+	assert.Equal(t, "HOW DO I RESET MY PASSWORD?", g[0].Text)
+	assert.Equal(t, true, g[0].GenerationInfo[GenerationInfoCacheHit])
+	assert.InDelta(t, 0.98, g[0].GenerationInfo[GenerationInfoSimilarity], 0.01)
+
+	// below the threshold
+	_, err = cached.Call(ctx, "how do I delete my account?")
+	assert.Nil(t, err)
+	_, err = cached.Call(ctx, "how do i reset my password, pls")
+	assert.Nil(t, err)
+
+	// other call options are not compared
+	_, err = cached.Call(ctx, "how can I reset my password?", llms.WithMaxTokens(10))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"how do I reset my password?", "how do I delete my account?", "how do i reset my password, pls", "how can I reset my password?"}, underlying.calls)
+	assert.Equal(t, Stats{Hits: 1, Misses: 4}, cached.Stats())
+
+	// namespaces are isolated
+	other, err := NewSemantic(underlying, embedder, index, WithNamespace("other"))
+	assert.Nil(t, err)
+
+	_, err = other.Call(ctx, "how do I reset my password?")
+	assert.Nil(t, err)
+	assert.Len(t, underlying.calls, 5)
+
+	assert.Equal(t, 4, index.Len(""))
+	assert.Equal(t, 1, index.Len("other"))
+
+	index.Clear("other")
+	assert.Equal(t, 0, index.Len("other"))
+}
+
+func TestSemanticIndexEviction(t *testing.T) {
+
+	embedder := tableEmbedder{
+		"a": {1, 0, 0},
+		"b": {0, 1, 0},
+		"c": {0, 0, 1},
+	}
+
+	underlying := &countingLLM{modelID: "anthropic.claude-v2"}
+	index := NewSemanticIndex(2)
+
+	cached, err := NewSemantic(underlying, embedder, index, WithOptions(WithTTL(time.Minute)))
+	assert.Nil(t, err)
+
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	for _, prompt := range []string{"a", "b", "a", "c", "b"} {
+		_, err := cached.Call(ctx, prompt)
+		assert.Nil(t, err)
+	}
+
+	// b was the least recently used entry when c was added
+	assert.Equal(t, []string{"a", "b", "c", "b"}, underlying.calls)
+	assert.Equal(t, 2, index.Len(""))
+
+	now = now.Add(time.Minute)
+	_, err = cached.Call(ctx, "c")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "b", "c"}, underlying.calls)
+	assert.Equal(t, 1, index.Len(""))
+}
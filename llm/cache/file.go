@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/internal/store"
+)
+
+// FileStore is a Store that keeps one JSON file per entry in a directory, so
+// that cached responses survive process restarts and can be checked in with
+// test fixtures. Expired entries are removed when they are read.
+type FileStore struct {
+	files *store.Files
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore creates a FileStore rooted at dir, creating dir if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	files, err := store.NewFiles(dir, ".json")
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{files: files}, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (Entry, bool, error) {
+
+	data, ok, err := s.files.Read(key)
+	if !ok || err != nil {
+		return Entry{}, false, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		// a partially written or foreign file, treat it as a miss
+		return Entry{}, false, nil
+	}
+
+	if entry.expired(time.Now()) {
+		return Entry{}, false, s.files.Remove(key)
+	}
+
+	return entry, true, nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, entry Entry) error {
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.files.Write(key, data)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/internal/store"
+)
+
+// MemoryStore is an in-memory Store that evicts the least recently used entry
+// once it holds more than its capacity. Expired entries are dropped when they
+// are read.
+type MemoryStore struct {
+	lru *store.LRU[Entry]
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore holding at most capacity entries. A
+// capacity of zero or less means unbounded.
+func NewMemoryStore(capacity int) *MemoryStore {
+	lru := store.NewLRU[Entry](capacity)
+	lru.Valid = func(e Entry) bool { return !e.expired(time.Now()) }
+	return &MemoryStore{lru: lru}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, bool, error) {
+	entry, ok := s.lru.Get(key)
+	return entry, ok, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, entry Entry) error {
+	s.lru.Set(key, entry)
+	return nil
+}
+
+// Len returns the number of entries currently held, including expired entries
+// that have not been read since they expired.
+func (s *MemoryStore) Len() int {
+	return s.lru.Len()
+}
@@ -0,0 +1,373 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const defaultSimilarityThreshold = 0.95
+
+// GenerationInfoSimilarity is the cosine similarity between the prompt and the
+// cached prompt, in the GenerationInfo of generations answered by a
+// SemanticLLM from its index.
+const GenerationInfoSimilarity = "cache_similarity"
+
+var (
+	ErrMissingEmbedder = errors.New("missing embedder")
+	ErrMissingIndex    = errors.New("missing index")
+)
+
+// SemanticLLM answers prompts that are similar enough to a prompt answered
+// before with the same response, for example paraphrased questions. Prompts
+// are embedded with an embedder such as amazontitan.TitanEmbedder and
+// compared by cosine similarity with the prompts in a SemanticIndex.
+//
+// Only prompts for the same model with the same call options are compared.
+// Temperature, TTL and replay options work as for LLM.
+type SemanticLLM struct {
+	llm            llms.LLM
+	embedder       embeddings.Embedder
+	index          *SemanticIndex
+	modelID        string
+	namespace      string
+	threshold      float64
+	ttl            time.Duration
+	maxTemperature float64
+	replayChunk    int
+	now            func() time.Time
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	skipped atomic.Int64
+}
+
+var _ llms.LLM = (*SemanticLLM)(nil)
+
+type SemanticOption func(*SemanticOptions)
+
+type SemanticOptions struct {
+	Options
+	// SimilarityThreshold is the lowest cosine similarity between two prompts
+	// for which one is answered with the response to the other.
+	SimilarityThreshold float64
+	// Namespace isolates the entries of a SemanticLLM from those of others
+	// sharing the same index.
+	Namespace string
+}
+
+// WithOptions applies the options shared with LLM, such as WithTTL, to a
+// SemanticLLM.
+func WithOptions(options ...Option) SemanticOption {
+	return func(o *SemanticOptions) {
+		for _, opt := range options {
+			opt(&o.Options)
+		}
+	}
+}
+
+// WithSimilarityThreshold sets the similarity threshold (0.95 by default).
+func WithSimilarityThreshold(t float64) SemanticOption {
+	return func(o *SemanticOptions) {
+		o.SimilarityThreshold = t
+	}
+}
+
+// WithNamespace sets the namespace, for example a tenant or a product.
+func WithNamespace(namespace string) SemanticOption {
+	return func(o *SemanticOptions) {
+		o.Namespace = namespace
+	}
+}
+
+// NewSemantic wraps llm so that responses are looked up in index, by the
+// embedding of the prompt, before llm is called, and added afterwards.
+func NewSemantic(llm llms.LLM, embedder embeddings.Embedder, index *SemanticIndex, options ...SemanticOption) (*SemanticLLM, error) {
+
+	if llm == nil {
+		return nil, ErrMissingLLM
+	}
+
+	if embedder == nil {
+		return nil, ErrMissingEmbedder
+	}
+
+	if index == nil {
+		return nil, ErrMissingIndex
+	}
+
+	opts := &SemanticOptions{SimilarityThreshold: defaultSimilarityThreshold}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	modelID := opts.ModelID
+	if m, ok := llm.(interface{ ModelID() string }); ok {
+		modelID = m.ModelID()
+	}
+
+	return &SemanticLLM{
+		llm:            llm,
+		embedder:       embedder,
+		index:          index,
+		modelID:        modelID,
+		namespace:      opts.Namespace,
+		threshold:      opts.SimilarityThreshold,
+		ttl:            opts.TTL,
+		maxTemperature: opts.MaxTemperature,
+		replayChunk:    opts.ReplayChunkSize,
+		now:            time.Now,
+	}, nil
+}
+
+func (c *SemanticLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	r, err := c.Generate(ctx, []string{prompt}, options...)
+	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
+		return "", err
+	}
+	if len(r) == 0 {
+		return "", ErrEmptyResponse
+	}
+	return r[0].Text, nil
+}
+
+func (c *SemanticLLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	generations := make([]*llms.Generation, 0, len(prompts))
+
+	for _, prompt := range prompts {
+		g, err := c.generate(ctx, prompt, opts, options)
+		if g != nil {
+			generations = append(generations, g)
+		}
+		if err != nil {
+			return generations, err
+		}
+	}
+
+	return generations, nil
+}
+
+func (c *SemanticLLM) generate(ctx context.Context, prompt string, opts *llms.CallOptions, options []llms.CallOption) (*llms.Generation, error) {
+
+	scope, ok := c.scope(opts)
+	if !ok {
+		c.skipped.Add(1)
+		return generateOne(ctx, c.llm, prompt, options)
+	}
+
+	vector, err := c.embedder.EmbedQuery(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, similarity, ok := c.index.lookup(c.namespace, scope, vector, c.threshold, c.now())
+	if ok {
+		c.hits.Add(1)
+
+		if opts.StreamingFunc != nil {
+			if err := replay(ctx, entry.Text, c.replayChunk, opts.StreamingFunc); err != nil {
+				return nil, err
+			}
+		}
+
+		return &llms.Generation{
+			Text:       entry.Text,
+			StopReason: entry.StopReason,
+			GenerationInfo: map[string]any{
+				GenerationInfoCacheHit:   true,
+				GenerationInfoSimilarity: similarity,
+			},
+		}, nil
+	}
+
+	c.misses.Add(1)
+
+	g, err := generateOne(ctx, c.llm, prompt, options)
+	if err != nil {
+		return g, err
+	}
+
+	entry = Entry{Text: g.Text, StopReason: g.StopReason, CreatedAt: c.now()}
+	if c.ttl > 0 {
+		entry.ExpiresAt = entry.CreatedAt.Add(c.ttl)
+	}
+
+	c.index.add(c.namespace, scope, vector, entry)
+
+	return g, nil
+}
+
+func (c *SemanticLLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, c, prompts, options...)
+}
+
+// Stats reports cache effectiveness since the SemanticLLM was created.
+func (c *SemanticLLM) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Skipped: c.skipped.Load()}
+}
+
+// scope identifies the model and call options, and is false if the call must
+// not be cached.
+func (c *SemanticLLM) scope(opts *llms.CallOptions) (string, bool) {
+
+	if opts.Temperature > c.maxTemperature {
+		return "", false
+	}
+
+	params, err := optionsKey(opts)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c.modelID))
+	h.Write([]byte{0})
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// SemanticIndex holds prompt embeddings and their responses in memory, by
+// namespace. Each namespace holds at most the capacity of the index, and
+// evicts the least recently used entry beyond it, so that one namespace
+// cannot evict the entries of another. The memory use of an index therefore
+// grows with the number of namespaces in it.
+type SemanticIndex struct {
+	capacity int
+
+	mu         sync.Mutex
+	namespaces map[string]*list.List
+}
+
+type semanticEntry struct {
+	scope  string
+	vector []float32
+	norm   float64
+	entry  Entry
+}
+
+// NewSemanticIndex creates a SemanticIndex holding at most capacity entries
+// per namespace. A capacity of zero or less means unbounded.
+func NewSemanticIndex(capacity int) *SemanticIndex {
+	return &SemanticIndex{
+		capacity:   capacity,
+		namespaces: map[string]*list.List{},
+	}
+}
+
+// Len returns the number of entries in namespace.
+func (i *SemanticIndex) Len(namespace string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if l, ok := i.namespaces[namespace]; ok {
+		return l.Len()
+	}
+	return 0
+}
+
+// Clear removes all entries of namespace.
+func (i *SemanticIndex) Clear(namespace string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.namespaces, namespace)
+}
+
+// lookup returns the most similar unexpired entry of scope in namespace, if
+// its similarity to vector is at least threshold.
+func (i *SemanticIndex) lookup(namespace, scope string, vector []float32, threshold float64, now time.Time) (Entry, float64, bool) {
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l, ok := i.namespaces[namespace]
+	if !ok {
+		return Entry{}, 0, false
+	}
+
+	norm := vectorNorm(vector)
+
+	var best *list.Element
+	bestSimilarity := threshold
+
+	for elem := l.Front(); elem != nil; {
+		next := elem.Next()
+		e := elem.Value.(*semanticEntry)
+
+		if e.entry.expired(now) {
+			l.Remove(elem)
+		} else if e.scope == scope {
+			if s := cosineSimilarity(vector, norm, e.vector, e.norm); s >= bestSimilarity {
+				best, bestSimilarity = elem, s
+			}
+		}
+
+		elem = next
+	}
+
+	if best == nil {
+		return Entry{}, 0, false
+	}
+
+	l.MoveToFront(best)
+	return best.Value.(*semanticEntry).entry, bestSimilarity, true
+}
+
+func (i *SemanticIndex) add(namespace, scope string, vector []float32, entry Entry) {
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l, ok := i.namespaces[namespace]
+	if !ok {
+		l = list.New()
+		i.namespaces[namespace] = l
+	}
+
+	l.PushFront(&semanticEntry{scope: scope, vector: vector, norm: vectorNorm(vector), entry: entry})
+
+	if i.capacity > 0 && l.Len() > i.capacity {
+		l.Remove(l.Back())
+	}
+}
+
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+func cosineSimilarity(a []float32, normA float64, b []float32, normB float64) float64 {
+
+	if len(a) != len(b) || normA == 0 || normB == 0 {
+		return 0
+	}
+
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+
+	return dot / (normA * normB)
+}
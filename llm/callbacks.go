@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/tmc/langchaingo/callbacks"
+)
+
+// RetryHandler is implemented by callbacks handlers that want to be told about
+// invocation attempts retried by the AWS SDK. It complements callbacks.Handler,
+// which has no retry events.
+type RetryHandler interface {
+	HandleLLMRetry(ctx context.Context, attempt int, err error)
+}
+
+// EmbeddingHandler is implemented by callbacks handlers that want to receive
+// events from embedders.
+type EmbeddingHandler interface {
+	HandleEmbeddingStart(ctx context.Context, texts []string)
+	HandleEmbeddingEnd(ctx context.Context, texts []string, embeddings [][]float32)
+	HandleEmbeddingError(ctx context.Context, err error)
+}
+
+type callbacksKey struct{}
+
+// ContextWithCallbacks returns ctx carrying handler, so that the clients an
+// LLM invokes models with, such as CircuitBreaker, can report events to the
+// handler of the LLM. The LLM and embedder types in this module use it for
+// every invocation.
+func ContextWithCallbacks(ctx context.Context, handler callbacks.Handler) context.Context {
+	if handler == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, callbacksKey{}, handler)
+}
+
+// CallbacksFromContext returns the handler set with ContextWithCallbacks, if
+// any.
+func CallbacksFromContext(ctx context.Context) (callbacks.Handler, bool) {
+	handler, ok := ctx.Value(callbacksKey{}).(callbacks.Handler)
+	return handler, ok
+}
+
+// RetryCallbacks returns the client options that report retried attempts of a
+// single invocation to handler. It returns nil if handler does not implement
+// RetryHandler.
+func RetryCallbacks(ctx context.Context, handler callbacks.Handler) []func(*bedrockruntime.Options) {
+	rh, ok := handler.(RetryHandler)
+	if !ok {
+		return nil
+	}
+
+	return []func(*bedrockruntime.Options){
+		func(o *bedrockruntime.Options) {
+			if o.Retryer == nil {
+				return
+			}
+			o.Retryer = &notifyingRetryer{RetryerV2: asRetryerV2(o.Retryer), notify: func(attempt int, err error) {
+				rh.HandleLLMRetry(ctx, attempt, err)
+			}}
+		},
+	}
+}
+
+// CallbackStreamingFunc wraps fn so that every chunk is also passed to
+// handler.HandleStreamingFunc.
+func CallbackStreamingFunc(handler callbacks.Handler, fn func(ctx context.Context, chunk []byte) error) func(ctx context.Context, chunk []byte) error {
+	if handler == nil {
+		return fn
+	}
+
+	return func(ctx context.Context, chunk []byte) error {
+		handler.HandleStreamingFunc(ctx, chunk)
+		return fn(ctx, chunk)
+	}
+}
+
+// notifyingRetryer calls notify every time the SDK asks for the delay before a
+// retry, which happens exactly once per retried attempt.
+type notifyingRetryer struct {
+	aws.RetryerV2
+	notify func(attempt int, err error)
+}
+
+func (r *notifyingRetryer) RetryDelay(attempt int, opErr error) (time.Duration, error) {
+	r.notify(attempt, opErr)
+	return r.RetryerV2.RetryDelay(attempt, opErr)
+}
+
+func asRetryerV2(r aws.Retryer) aws.RetryerV2 {
+	if v2, ok := r.(aws.RetryerV2); ok {
+		return v2
+	}
+	return retryerV1{r}
+}
+
+type retryerV1 struct {
+	aws.Retryer
+}
+
+func (r retryerV1) GetAttemptToken(context.Context) (func(error) error, error) {
+	return r.GetInitialToken(), nil
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/callbacks"
+)
+
+type recordingHandler struct {
+	callbacks.SimpleHandler
+	chunks  []string
+	retries []int
+	circuit []CircuitStateChange
+}
+
+func (h *recordingHandler) HandleCircuitStateChange(_ context.Context, change CircuitStateChange) {
+	h.circuit = append(h.circuit, change)
+}
+
+func (h *recordingHandler) HandleStreamingFunc(_ context.Context, chunk []byte) {
+	h.chunks = append(h.chunks, string(chunk))
+}
+
+func (h *recordingHandler) HandleLLMRetry(_ context.Context, attempt int, _ error) {
+	h.retries = append(h.retries, attempt)
+}
+
+func TestCallbackStreamingFunc(t *testing.T) {
+
+	h := &recordingHandler{}
+	var got []string
+
+	fn := CallbackStreamingFunc(h, func(_ context.Context, chunk []byte) error {
+		got = append(got, string(chunk))
+		return nil
+	})
+
+	assert.NoError(t, fn(context.Background(), []byte("a")))
+	assert.NoError(t, fn(context.Background(), []byte("b")))
+	assert.Equal(t, []string{"a", "b"}, h.chunks)
+	assert.Equal(t, []string{"a", "b"}, got)
+}
+
+func TestRetryCallbacks(t *testing.T) {
+
+	assert.Nil(t, RetryCallbacks(context.Background(), callbacks.SimpleHandler{}))
+
+	h := &recordingHandler{}
+	o := &bedrockruntime.Options{Retryer: retry.NewStandard()}
+	for _, fn := range RetryCallbacks(context.Background(), h) {
+		fn(o)
+	}
+
+	_, err := o.Retryer.RetryDelay(1, errors.New("boom"))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, h.retries)
+}
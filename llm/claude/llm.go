@@ -5,24 +5,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 
 	"github.com/abhirockzz/amazon-bedrock-go-inference-params/claude"
 	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/schema"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrEmptyResponse = errors.New("empty response")
 
 type LLM struct {
 	CallbacksHandler        callbacks.Handler
-	brc                     *bedrockruntime.Client
+	brc                     llm.RuntimeClient
 	useHumanAssistantPrompt bool
 	modelID                 string
+	baseModelID             string
+	logger                  *slog.Logger
+	tracer                  trace.Tracer
+	metrics                 llm.MetricsRecorder
+	tokens                  llm.TokenCounter
+	limiter                 *llm.RateLimiter
 }
 
 var (
@@ -40,24 +45,20 @@ func New(region string, options ...llm.ConfigOption) (*LLM, error) {
 		return nil, ErrMissingRegion
 	}
 
-	claudeLLM := &LLM{useHumanAssistantPrompt: true, modelID: claudeV2ModelID}
+	claudeLLM := &LLM{useHumanAssistantPrompt: true, modelID: claudeV2ModelID, logger: llm.NopLogger()}
 
 	opts := &llm.ConfigOptions{}
 	for _, opt := range options {
 		opt(opts)
 	}
 
-	if opts.BedrockRuntimeClient == nil {
-		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
-		if err != nil {
-			return nil, err
-		}
-
-		claudeLLM.brc = bedrockruntime.NewFromConfig(cfg)
-	} else {
-		claudeLLM.brc = opts.BedrockRuntimeClient
+	brc, err := llm.NewRuntimeClient(context.Background(), region, opts)
+	if err != nil {
+		return nil, err
 	}
 
+	claudeLLM.brc = brc
+
 	if opts.DontUseHumanAssistantPrompt {
 		claudeLLM.useHumanAssistantPrompt = false
 	}
@@ -66,12 +67,35 @@ func New(region string, options ...llm.ConfigOption) (*LLM, error) {
 		claudeLLM.modelID = opts.ModelID
 	}
 
+	claudeLLM.baseModelID = llm.ResolveBaseModel(claudeLLM.modelID, opts)
+
+	if err := llm.CheckModel(claudeLLM.baseModelID, llm.ProviderAnthropic, llm.ModalityText); err != nil {
+		return nil, err
+	}
+
+	if opts.Logger != nil {
+		claudeLLM.logger = opts.Logger
+	}
+
+	claudeLLM.tracer = llm.Tracer(opts.TracerProvider)
+	claudeLLM.metrics = opts.Metrics
+
+	claudeLLM.tokens = opts.TokenCounter
+	if claudeLLM.tokens == nil {
+		claudeLLM.tokens = llm.TokenCounterFor(claudeLLM.baseModelID)
+	}
+
+	claudeLLM.limiter = opts.RateLimiter
+
 	return claudeLLM, nil
 }
 
 func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
 	r, err := o.Generate(ctx, []string{prompt}, options...)
 	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
 		return "", err
 	}
 	if len(r) == 0 {
@@ -82,117 +106,180 @@ func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOptio
 
 const (
 	claudePromptFormat = "\n\nHuman:%s\n\nAssistant:"
-	claudeV2ModelID    = "anthropic.claude-v2" //https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+	claudeV2ModelID    = llm.ModelClaudeV2
 )
 
 func (o *LLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+	return o.generateWithCallbacks(ctx, prompts, "", options)
+}
+
+// GeneratePrefilled generates the response to prompt as if the model had
+// already begun it with prefill, for example "{" to have it answer with a JSON
+// object right away. The text of the returned generation starts with prefill;
+// the chunks passed to a streaming function do not include it.
+func (o *LLM) GeneratePrefilled(ctx context.Context, prompt, prefill string, options ...llms.CallOption) ([]*llms.Generation, error) {
+	return o.generateWithCallbacks(ctx, []string{prompt}, prefill, options)
+}
+
+func (o *LLM) generateWithCallbacks(ctx context.Context, prompts []string, prefill string, options []llms.CallOption) ([]*llms.Generation, error) {
 	if o.CallbacksHandler != nil {
 		o.CallbacksHandler.HandleLLMStart(ctx, prompts)
 	}
 
-	opts := &llms.CallOptions{}
-	for _, opt := range options {
-		opt(opts)
+	generations, err := o.generate(ctx, prompts, prefill, options...)
+	if err != nil {
+		if o.CallbacksHandler != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+		}
+		return generations, err
 	}
 
-	payload := claude.Request{
-		//Prompt: fmt.Sprintf(claudePromptFormat, prompts[0]),
-		MaxTokensToSample: opts.MaxTokens,
-		Temperature:       opts.Temperature,
-		TopK:              opts.TopK,
-		TopP:              opts.TopP,
-		StopSequences:     opts.StopWords,
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
 	}
+	return generations, nil
+}
 
-	if o.useHumanAssistantPrompt {
-		payload.Prompt = fmt.Sprintf(claudePromptFormat, prompts[0])
-	} else {
-		payload.Prompt = prompts[0]
-	}
+func (o *LLM) generate(ctx context.Context, prompts []string, prefill string, options ...llms.CallOption) ([]*llms.Generation, error) {
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
 	}
 
-	//log.Println("payload\n", string(payloadBytes))
-
-	var resp claude.Response
-
 	if opts.StreamingFunc != nil {
 
-		resp, err = o.invokeAsyncAndGetResponse(payloadBytes, opts.StreamingFunc)
+		stream, err := o.stream(ctx, prompts[0], prefill, opts, nil)
 		if err != nil {
 			return nil, err
 		}
 
-	} else {
-		resp, err = o.invokeAndGetResponse(payloadBytes)
-		if err != nil {
-			return nil, err
+		if err := stream.Drain(); err != nil {
+			return []*llms.Generation{{Text: prefill + stream.Text()}}, err
 		}
+
+		return []*llms.Generation{
+			{Text: prefill + stream.Text(), StopReason: stream.StopReason()},
+		}, nil
 	}
 
-	generations := []*llms.Generation{
-		{Text: resp.Completion},
+	err := llm.ValidateCallOptions(o.baseModelID, opts, false)
+	if err != nil {
+		return nil, err
 	}
 
-	if o.CallbacksHandler != nil {
-		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
+	payloadBytes, err := o.request(prompts[0], prefill, opts)
+	if err != nil {
+		return nil, err
 	}
-	return generations, nil
-}
 
-func (o *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
-	return llms.GeneratePrompt(ctx, o, prompts, options...)
-}
+	var resp response
 
-func (o *LLM) GetNumTokens(text string) int {
-	return llms.CountTokens("gpt4", text)
+	err = o.invoker().Invoke(ctx, o.invocation(payloadBytes, prompts[0], opts), func(body []byte) (string, error) {
+		err := json.Unmarshal(body, &resp)
+		return resp.StopReason, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	generations := []*llms.Generation{
+		{Text: prefill + resp.Completion, StopReason: resp.StopReason},
+	}
+
+	return generations, nil
 }
 
-func (o *LLM) invokeAndGetResponse(payloadBytes []byte) (claude.Response, error) {
+func (o *LLM) request(prompt, prefill string, opts *llms.CallOptions) ([]byte, error) {
 
-	output, err := o.brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(o.modelID),
-		ContentType: aws.String("application/json"),
-	})
+	payload := claude.Request{
+		//Prompt: fmt.Sprintf(claudePromptFormat, prompts[0]),
+		MaxTokensToSample: opts.MaxTokens,
+		Temperature:       opts.Temperature,
+		TopK:              opts.TopK,
+		TopP:              opts.TopP,
+		StopSequences:     opts.StopWords,
+	}
 
+	if o.useHumanAssistantPrompt {
+		payload.Prompt = fmt.Sprintf(claudePromptFormat, prompt)
+		if prefill != "" {
+			payload.Prompt += " " + prefill
+		}
+	} else {
+		payload.Prompt = prompt + prefill
+	}
+
+	err := llm.CheckPromptSize(o.tokens, o.baseModelID, payload.Prompt, opts.MaxTokens)
 	if err != nil {
-		return claude.Response{}, err
+		return nil, err
 	}
 
-	var resp claude.Response
+	return json.Marshal(payload)
+}
 
-	err = json.Unmarshal(output.Body, &resp)
+func (o *LLM) spanParams(opts *llms.CallOptions) llm.SpanParams {
+	return llm.SpanParams{
+		Operation:     llm.OperationTextCompletion,
+		ModelID:       o.modelID,
+		MaxTokens:     opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		TopK:          opts.TopK,
+		StopSequences: opts.StopWords,
+	}
+}
 
-	if err != nil {
-		return claude.Response{}, err
+// invocation returns the invocation sending body, the request for prompt
+// with opts.
+func (o *LLM) invocation(body []byte, prompt string, opts *llms.CallOptions) llm.Invocation {
+	return llm.Invocation{
+		Body:            body,
+		Span:            o.spanParams(opts),
+		EstimatedTokens: o.tokens.CountTokens(prompt) + opts.MaxTokens,
 	}
+}
 
-	return resp, nil
+func (o *LLM) invoker() llm.Invoker {
+	return llm.Invoker{
+		Client:    o.brc,
+		ModelID:   o.modelID,
+		Callbacks: o.CallbacksHandler,
+		Logger:    o.logger,
+		Tracer:    o.tracer,
+		Metrics:   o.metrics,
+		Limiter:   o.limiter,
+	}
 }
 
-func (o *LLM) invokeAsyncAndGetResponse(payloadBytes []byte, handler func(ctx context.Context, chunk []byte) error) (claude.Response, error) {
+func (o *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, o, prompts, options...)
+}
 
-	output, err := o.brc.InvokeModelWithResponseStream(context.Background(), &bedrockruntime.InvokeModelWithResponseStreamInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(o.modelID),
-		ContentType: aws.String("application/json"),
-	})
+// RenderRequest returns the request body sent to Bedrock for prompt with
+// options.
+func (o *LLM) RenderRequest(prompt string, options ...llms.CallOption) ([]byte, error) {
 
-	if err != nil {
-		return claude.Response{}, err
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
 	}
 
-	var resp claude.Response
+	return o.request(prompt, "", opts)
+}
 
-	resp, err = ProcessStreamingOutput(output, handler)
+// ModelID returns the Bedrock model identifier used for invocations.
+func (o *LLM) ModelID() string {
+	return o.modelID
+}
 
-	if err != nil {
-		return claude.Response{}, err
-	}
+func (o *LLM) GetNumTokens(text string) int {
+	return o.tokens.CountTokens(text)
+}
 
-	return resp, nil
+// response is the model response together with the stop reason the inference
+// parameters type does not carry.
+type response struct {
+	claude.Response
+	StopReason string `json:"stop_reason"`
 }
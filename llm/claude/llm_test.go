@@ -2,10 +2,13 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/oteltest"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/stretchr/testify/assert"
@@ -119,3 +122,60 @@ func TestGenerateWithStreamingResponse(t *testing.T) {
 
 	assert.Contains(t, generations[0].Text, "Claude")
 }
+
+func TestGeneratePrefilled(t *testing.T) {
+
+	claudeLLM, err := New("us-east-1")
+
+	assert.Nil(t, err)
+
+	request, err := claudeLLM.request("what's your name? answer in JSON", "{", &llms.CallOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, string(request), `Assistant: {"`)
+
+	generations, err := claudeLLM.GeneratePrefilled(context.Background(), `what's your name? answer in JSON with a "name" field`, "{", llms.WithMaxTokens(100))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(generations))
+
+	assert.True(t, strings.HasPrefix(generations[0].Text, "{"))
+	assert.Contains(t, generations[0].Text, "Claude")
+}
+
+func TestGenerateWithFailingStreamingFunc(t *testing.T) {
+
+	claudeLLM, err := New("us-east-1")
+
+	assert.Nil(t, err)
+
+	errDisconnected := errors.New("client disconnected")
+	chunks := 0
+
+	generations, err := claudeLLM.Generate(context.Background(), []string{"count from 1 to 100"}, llms.WithMaxTokens(500), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		chunks++
+		return errDisconnected
+	}))
+
+	assert.ErrorIs(t, err, llm.ErrStreamAborted)
+	assert.ErrorIs(t, err, errDisconnected)
+	assert.Equal(t, 1, chunks)
+
+	assert.Equal(t, 1, len(generations))
+	assert.NotEmpty(t, generations[0].Text)
+}
+
+func TestGenerateWithTracing(t *testing.T) {
+
+	tp, exporter := oteltest.NewTracerProvider()
+
+	claudeLLM, err := New("us-east-1", llm.WithTracerProvider(tp))
+
+	assert.Nil(t, err)
+
+	_, err = claudeLLM.Generate(context.Background(), []string{"what's your name?"}, llms.WithMaxTokens(100))
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "text_completion anthropic.claude-v2", spans[0].Name)
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// RuntimeClient is the part of *bedrockruntime.Client used by the types in
+// this module.
+type RuntimeClient interface {
+	InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+	InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error)
+}
+
+var _ RuntimeClient = (*bedrockruntime.Client)(nil)
+
+// NewRuntimeClient returns the client set in opts with WithRuntimeClient or
+// WithBedrockRuntimeClient. Otherwise it creates one for region from the
+// default AWS configuration or, if opts has failover regions, a
+// FailoverClient for region followed by those regions. With
+// WithCircuitBreaker, every regional client (or the client set in opts) is
+// wrapped in a CircuitBreaker. With WithHedging, the resulting client is
+// wrapped in a HedgingClient.
+func NewRuntimeClient(ctx context.Context, region string, opts *ConfigOptions) (RuntimeClient, error) {
+
+	client, err := newRuntimeClient(ctx, region, opts)
+	if err != nil || !opts.Hedging {
+		return client, err
+	}
+
+	options := append([]HedgeOption{
+		WithHedgeLogger(opts.Logger),
+		WithHedgeMetrics(opts.Metrics),
+	}, opts.HedgeOptions...)
+
+	return NewHedgingClient(client, options...), nil
+}
+
+func newRuntimeClient(ctx context.Context, region string, opts *ConfigOptions) (RuntimeClient, error) {
+
+	if opts.RuntimeClient != nil {
+		return withCircuitBreaker(opts.RuntimeClient, "", opts), nil
+	}
+
+	if opts.BedrockRuntimeClient != nil {
+		return withCircuitBreaker(opts.BedrockRuntimeClient, region, opts), nil
+	}
+
+	regions := append([]string{region}, opts.FailoverRegions...)
+	targets := make([]FailoverTarget, 0, len(regions))
+
+	for _, r := range regions {
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(r))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, FailoverTarget{Name: r, Client: withCircuitBreaker(bedrockruntime.NewFromConfig(cfg), r, opts)})
+	}
+
+	if len(targets) == 1 {
+		return targets[0].Client, nil
+	}
+
+	return NewFailoverClient(targets, WithFailoverLogger(opts.Logger)), nil
+}
+
+func withCircuitBreaker(client RuntimeClient, name string, opts *ConfigOptions) RuntimeClient {
+	if !opts.CircuitBreaker {
+		return client
+	}
+
+	options := append([]CircuitBreakerOption{
+		WithBreakerName(name),
+		WithBreakerLogger(opts.Logger),
+		WithBreakerMetrics(opts.Metrics),
+	}, opts.CircuitBreakerOptions...)
+
+	return NewCircuitBreaker(client, options...)
+}
@@ -4,22 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/abhirockzz/amazon-bedrock-go-inference-params/cohere"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/schema"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrEmptyResponse = errors.New("empty response")
 
 type LLM struct {
 	CallbacksHandler callbacks.Handler
-	brc              *bedrockruntime.Client
+	brc              llm.RuntimeClient
+	modelID          string
+	baseModelID      string
+	logger           *slog.Logger
+	tracer           trace.Tracer
+	metrics          llm.MetricsRecorder
+	tokens           llm.TokenCounter
+	limiter          *llm.RateLimiter
 }
 
 var (
@@ -31,27 +37,61 @@ var (
 	ErrMissingRegion = errors.New("empty region")
 )
 
-const cohereCommandModelID = "cohere.command-text-v14" //https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+const cohereCommandModelID = llm.ModelCohereCommand
 
-func New(region string) (*LLM, error) {
+func New(region string, options ...llm.ConfigOption) (*LLM, error) {
 
 	if region == "" {
 		return nil, ErrMissingRegion
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	cohereLLM := &LLM{modelID: cohereCommandModelID, logger: llm.NopLogger()}
+
+	opts := &llm.ConfigOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	brc, err := llm.NewRuntimeClient(context.Background(), region, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &LLM{
-		brc: bedrockruntime.NewFromConfig(cfg),
-	}, nil
+	cohereLLM.brc = brc
+
+	if opts.ModelID != "" {
+		cohereLLM.modelID = opts.ModelID
+	}
+
+	cohereLLM.baseModelID = llm.ResolveBaseModel(cohereLLM.modelID, opts)
+
+	if err := llm.CheckModel(cohereLLM.baseModelID, llm.ProviderCohere, llm.ModalityText); err != nil {
+		return nil, err
+	}
+
+	if opts.Logger != nil {
+		cohereLLM.logger = opts.Logger
+	}
+
+	cohereLLM.tracer = llm.Tracer(opts.TracerProvider)
+	cohereLLM.metrics = opts.Metrics
+
+	cohereLLM.tokens = opts.TokenCounter
+	if cohereLLM.tokens == nil {
+		cohereLLM.tokens = llm.TokenCounterFor(cohereLLM.baseModelID)
+	}
+
+	cohereLLM.limiter = opts.RateLimiter
+
+	return cohereLLM, nil
 }
 
 func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
 	r, err := o.Generate(ctx, []string{prompt}, options...)
 	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
 		return "", err
 	}
 	if len(r) == 0 {
@@ -65,60 +105,186 @@ func (o *LLM) Generate(ctx context.Context, prompts []string, options ...llms.Ca
 		o.CallbacksHandler.HandleLLMStart(ctx, prompts)
 	}
 
+	generations, err := o.generate(ctx, prompts, options...)
+	if err != nil {
+		if o.CallbacksHandler != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+		}
+		return generations, err
+	}
+
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
+	}
+	return generations, nil
+}
+
+func (o *LLM) generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
 	opts := &llms.CallOptions{}
 	for _, opt := range options {
 		opt(opts)
 	}
 
-	payload := cohere.Request{
-		Prompt:            prompts[0],
-		Temperature:       opts.Temperature,
-		P:                 opts.TopP,
-		K:                 float64(opts.TopK),
-		MaxTokens:         opts.MaxTokens,
-		StopSequences:     opts.StopWords,
-		ReturnLikelihoods: cohere.None,
+	if opts.StreamingFunc != nil {
+
+		stream, err := o.stream(ctx, prompts[0], opts, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := stream.Drain(); err != nil {
+			return []*llms.Generation{{Text: stream.Text()}}, err
+		}
+
+		return []*llms.Generation{
+			{Text: stream.Text(), StopReason: stream.StopReason()},
+		}, nil
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	err := llm.ValidateCallOptions(o.baseModelID, opts, false)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	payloadBytes, err := o.request(prompts[0], opts)
+	if err != nil {
+		return nil, err
 	}
 
-	//log.Println("payload\n", string(payloadBytes))
+	var resp cohere.Response
+	var finishReason string
 
-	output, err := o.brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(cohereCommandModelID),
-		ContentType: aws.String("application/json"),
+	err = o.invoker().Invoke(ctx, o.invocation(payloadBytes, prompts[0], opts), func(body []byte) (string, error) {
+		var err error
+		resp, finishReason, err = decodeResponse(body)
+		return finishReason, err
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	generations := []*llms.Generation{
+		{Text: resp.Generations[0].Text, StopReason: finishReason},
+	}
 
+	return generations, nil
+}
+
+func (o *LLM) request(prompt string, opts *llms.CallOptions) ([]byte, error) {
+
+	payload, err := o.payload(prompt, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var resp cohere.Response
+	return json.Marshal(payload)
+}
 
-	err = json.Unmarshal(output.Body, &resp)
+func (o *LLM) payload(prompt string, opts *llms.CallOptions) (cohere.Request, error) {
 
+	payload := cohere.Request{
+		Prompt:            prompt,
+		Temperature:       opts.Temperature,
+		P:                 opts.TopP,
+		K:                 float64(opts.TopK),
+		MaxTokens:         opts.MaxTokens,
+		StopSequences:     opts.StopWords,
+		ReturnLikelihoods: cohere.None,
+	}
+
+	err := llm.CheckPromptSize(o.tokens, o.baseModelID, payload.Prompt, opts.MaxTokens)
 	if err != nil {
-		log.Fatal("failed to unmarshal", err)
+		return cohere.Request{}, err
 	}
 
-	generations := []*llms.Generation{
-		{Text: resp.Generations[0].Text},
+	return payload, nil
+}
+
+func (o *LLM) spanParams(opts *llms.CallOptions) llm.SpanParams {
+	return llm.SpanParams{
+		Operation:     llm.OperationTextCompletion,
+		ModelID:       o.modelID,
+		MaxTokens:     opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		TopK:          opts.TopK,
+		StopSequences: opts.StopWords,
+	}
+}
+
+// invocation returns the invocation sending body, the request for prompt
+// with opts.
+func (o *LLM) invocation(body []byte, prompt string, opts *llms.CallOptions) llm.Invocation {
+	return llm.Invocation{
+		Body:            body,
+		Span:            o.spanParams(opts),
+		EstimatedTokens: o.tokens.CountTokens(prompt) + opts.MaxTokens,
 	}
+}
 
-	if o.CallbacksHandler != nil {
-		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
+func (o *LLM) invoker() llm.Invoker {
+	return llm.Invoker{
+		Client:    o.brc,
+		ModelID:   o.modelID,
+		Callbacks: o.CallbacksHandler,
+		Logger:    o.logger,
+		Tracer:    o.tracer,
+		Metrics:   o.metrics,
+		Limiter:   o.limiter,
 	}
-	return generations, nil
 }
 
 func (o *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
 	return llms.GeneratePrompt(ctx, o, prompts, options...)
 }
 
+// RenderRequest returns the request body sent to Bedrock for prompt with
+// options.
+func (o *LLM) RenderRequest(prompt string, options ...llms.CallOption) ([]byte, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return o.request(prompt, opts)
+}
+
+// ModelID returns the Bedrock model identifier used for invocations.
+func (o *LLM) ModelID() string {
+	return o.modelID
+}
+
 func (o *LLM) GetNumTokens(text string) int {
-	return llms.CountTokens("gpt4", text)
+	return o.tokens.CountTokens(text)
+}
+
+// decodeResponse decodes the body of a response and its finish reason.
+func decodeResponse(body []byte) (cohere.Response, string, error) {
+
+	var resp cohere.Response
+
+	err := json.Unmarshal(body, &resp)
+
+	if err != nil {
+		return cohere.Response{}, "", err
+	}
+
+	if len(resp.Generations) == 0 {
+		return cohere.Response{}, "", ErrEmptyResponse
+	}
+
+	// the finish reason is not part of the inference parameters response type
+	var finish struct {
+		Generations []struct {
+			FinishReason string `json:"finish_reason"`
+		} `json:"generations"`
+	}
+
+	if json.Unmarshal(body, &finish) == nil && len(finish.Generations) > 0 {
+		return resp, finish.Generations[0].FinishReason, nil
+	}
+
+	return resp, "", nil
 }
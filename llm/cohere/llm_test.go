@@ -2,8 +2,11 @@ package cohere
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
 	"github.com/stretchr/testify/assert"
 	"github.com/tmc/langchaingo/llms"
 )
@@ -22,3 +25,49 @@ func TestGenerate(t *testing.T) {
 
 	//assert.Contains(t, generations[0].Text, "Cohere")
 }
+
+func TestGenerateWithStreamingResponse(t *testing.T) {
+
+	cohereLLM, err := New("us-east-1")
+
+	assert.Nil(t, err)
+
+	generations, err := cohereLLM.Generate(context.Background(), []string{"what's your name?"}, llms.WithMaxTokens(100), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		fmt.Println(string(chunk))
+		return nil
+	}))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(generations))
+	assert.Nil(t, err)
+}
+
+func TestStreamRequest(t *testing.T) {
+
+	cohereLLM := &LLM{modelID: cohereCommandModelID, baseModelID: cohereCommandModelID, tokens: llm.TokenCounterFor(cohereCommandModelID)}
+
+	payload, err := cohereLLM.payload("hi", &llms.CallOptions{MaxTokens: 10})
+	assert.Nil(t, err)
+
+	body, err := json.Marshal(streamRequest{Request: payload, Stream: true})
+	assert.Nil(t, err)
+
+	var fields map[string]any
+	assert.Nil(t, json.Unmarshal(body, &fields))
+	assert.Equal(t, "hi", fields["prompt"])
+	assert.Equal(t, true, fields["stream"])
+}
+
+func TestDecodeChunk(t *testing.T) {
+
+	events, err := decodeChunk([]byte(`{"text":" Hello","is_finished":false}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []llm.StreamEvent{{Type: llm.StreamEventText, Text: " Hello"}}, events)
+
+	events, err = decodeChunk([]byte(`{"is_finished":true,"finish_reason":"COMPLETE","amazon-bedrock-invocationMetrics":{"inputTokenCount":3,"outputTokenCount":4}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []llm.StreamEvent{
+		{Type: llm.StreamEventStop, StopReason: "COMPLETE"},
+		{Type: llm.StreamEventUsage, Usage: llm.Usage{InputTokens: 3, OutputTokens: 4}},
+	}, events)
+}
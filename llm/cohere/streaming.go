@@ -0,0 +1,99 @@
+package cohere
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/abhirockzz/amazon-bedrock-go-inference-params/cohere"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// streamRequest is a request with streaming enabled, which the inference
+// parameters request type has no field for.
+type streamRequest struct {
+	cohere.Request
+	Stream bool `json:"stream"`
+}
+
+// streamChunk is a chunk of a streamed response. The last chunk has
+// IsFinished set and the finish reason; its text, if any, is not part of the
+// completion.
+type streamChunk struct {
+	Text         string `json:"text"`
+	IsFinished   bool   `json:"is_finished"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// Stream starts a streamed completion of prompt. The caller must close the
+// returned stream. llms.WithStreamingFunc, if given, is called with every
+// piece of text as well.
+func (o *LLM) Stream(ctx context.Context, prompt string, options ...llms.CallOption) (*llm.Stream, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMStart(ctx, []string{prompt})
+	}
+
+	stream, err := o.stream(ctx, prompt, opts, func(text, stopReason string, _ llm.Usage, err error) {
+		if o.CallbacksHandler == nil {
+			return
+		}
+		if err != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+			return
+		}
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{{{Text: text, StopReason: stopReason}}}})
+	})
+
+	if err != nil && o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMError(ctx, err)
+	}
+
+	return stream, err
+}
+
+// stream invokes the model and returns its response stream. done, if not nil,
+// is called once the stream has ended.
+func (o *LLM) stream(ctx context.Context, prompt string, opts *llms.CallOptions, done func(text, stopReason string, usage llm.Usage, err error)) (*llm.Stream, error) {
+
+	err := llm.ValidateCallOptions(o.baseModelID, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := o.payload(prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadBytes, err := json.Marshal(streamRequest{Request: payload, Stream: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return o.invoker().Stream(ctx, o.invocation(payloadBytes, prompt, opts), llm.StreamConfig{
+		Decode:  decodeChunk,
+		Handler: opts.StreamingFunc,
+		Done:    done,
+	})
+}
+
+var decodeChunk = llm.JSONChunkDecoder(
+	func(c *streamChunk) string {
+		if c.IsFinished {
+			return ""
+		}
+		return c.Text
+	},
+	func(c *streamChunk) string {
+		if !c.IsFinished {
+			return ""
+		}
+		return c.FinishReason
+	},
+)
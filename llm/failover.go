@@ -0,0 +1,238 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	defaultFailoverCooldown    = 30 * time.Second
+	defaultFailoverMaxCooldown = 10 * time.Minute
+)
+
+// FailoverTarget is one of the clients of a FailoverClient, usually for one
+// region. Name identifies it in logs and in Health.
+type FailoverTarget struct {
+	Name   string
+	Client RuntimeClient
+}
+
+// TargetHealth is the state of a FailoverTarget.
+type TargetHealth struct {
+	Name                string
+	Healthy             bool
+	ConsecutiveFailures int
+	UnhealthyUntil      time.Time
+}
+
+// FailoverClient is a RuntimeClient that sends each invocation to the first
+// healthy one of an ordered list of clients. A client failing with an error
+// for which IsFailoverError is true is marked unhealthy for a cooldown period,
+// which doubles with every consecutive failure, and the invocation moves on to
+// the next client. Once its cooldown has passed, a preferred client gets
+// traffic again and keeps it as long as it succeeds.
+//
+// Streams fail over only when the invocation itself fails, not once the
+// response stream has started.
+type FailoverClient struct {
+	targets     []*failoverTarget
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	logger      *slog.Logger
+	now         func() time.Time
+
+	mu sync.Mutex
+}
+
+type failoverTarget struct {
+	FailoverTarget
+	failures       int
+	unhealthyUntil time.Time
+}
+
+var _ RuntimeClient = (*FailoverClient)(nil)
+
+type FailoverOption func(*FailoverClient)
+
+// WithCooldown sets how long a failed client is skipped after its first
+// failure (30 seconds by default) and the maximum the doubling cooldown can
+// reach (10 minutes by default).
+func WithCooldown(cooldown, max time.Duration) FailoverOption {
+	return func(c *FailoverClient) {
+		c.cooldown = cooldown
+		c.maxCooldown = max
+	}
+}
+
+// WithFailoverLogger logs every failover at warning level.
+func WithFailoverLogger(logger *slog.Logger) FailoverOption {
+	return func(c *FailoverClient) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// NewFailoverClient returns a FailoverClient over targets, in order of
+// preference.
+func NewFailoverClient(targets []FailoverTarget, options ...FailoverOption) *FailoverClient {
+
+	c := &FailoverClient{
+		cooldown:    defaultFailoverCooldown,
+		maxCooldown: defaultFailoverMaxCooldown,
+		logger:      NopLogger(),
+		now:         time.Now,
+	}
+
+	for _, t := range targets {
+		c.targets = append(c.targets, &failoverTarget{FailoverTarget: t})
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *FailoverClient) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	return invokeWithFailover(ctx, c, func(client RuntimeClient) (*bedrockruntime.InvokeModelOutput, error) {
+		return client.InvokeModel(ctx, params, optFns...)
+	})
+}
+
+func (c *FailoverClient) InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+	return invokeWithFailover(ctx, c, func(client RuntimeClient) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+		return client.InvokeModelWithResponseStream(ctx, params, optFns...)
+	})
+}
+
+// Health returns the state of every target, in order of preference.
+func (c *FailoverClient) Health() []TargetHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	health := make([]TargetHealth, 0, len(c.targets))
+	for _, t := range c.targets {
+		health = append(health, TargetHealth{
+			Name:                t.Name,
+			Healthy:             !now.Before(t.unhealthyUntil),
+			ConsecutiveFailures: t.failures,
+			UnhealthyUntil:      t.unhealthyUntil,
+		})
+	}
+	return health
+}
+
+func invokeWithFailover[T any](ctx context.Context, c *FailoverClient, call func(RuntimeClient) (T, error)) (T, error) {
+
+	var zero T
+	var lastErr error
+
+	for _, t := range c.order() {
+		out, err := call(t.Client)
+		if err == nil {
+			c.succeeded(t)
+			return out, nil
+		}
+
+		if !IsFailoverError(err) || ctx.Err() != nil {
+			return zero, err
+		}
+
+		c.failed(ctx, t, err)
+		lastErr = err
+	}
+
+	return zero, lastErr
+}
+
+// order returns the healthy targets in order of preference followed by the
+// unhealthy ones, soonest to recover first, so that an invocation is still
+// attempted when every target is unhealthy.
+func (c *FailoverClient) order() []*failoverTarget {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var healthy, unhealthy []*failoverTarget
+	for _, t := range c.targets {
+		if now.Before(t.unhealthyUntil) {
+			unhealthy = append(unhealthy, t)
+		} else {
+			healthy = append(healthy, t)
+		}
+	}
+
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return unhealthy[i].unhealthyUntil.Before(unhealthy[j].unhealthyUntil)
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+func (c *FailoverClient) succeeded(t *failoverTarget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t.failures = 0
+	t.unhealthyUntil = time.Time{}
+}
+
+func (c *FailoverClient) failed(ctx context.Context, t *failoverTarget, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cooldown := c.cooldown << t.failures
+	if cooldown > c.maxCooldown || cooldown <= 0 {
+		cooldown = c.maxCooldown
+	}
+
+	t.failures++
+	t.unhealthyUntil = c.now().Add(cooldown)
+
+	c.logger.WarnContext(ctx, "failing over",
+		slog.String("target", t.Name),
+		slog.Int("consecutive_failures", t.failures),
+		slog.Duration("cooldown", cooldown),
+		slog.String("error", err.Error()))
+}
+
+// IsFailoverError reports whether err suggests that the same invocation may
+// succeed with another client: throttling, exhausted quotas, server errors,
+// connection failures and open circuit breakers. Cancellations and client
+// errors are not.
+func IsFailoverError(err error) bool {
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if IsThrottling(err) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var quota *types.ServiceQuotaExceededException
+	if errors.As(err, &quota) {
+		return true
+	}
+
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		code := re.HTTPStatusCode()
+		return code == 429 || code >= 500
+	}
+
+	var se *smithyhttp.RequestSendError
+	return errors.As(err, &se)
+}
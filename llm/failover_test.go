@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+// standIn is a local stand-in for a regional Bedrock endpoint.
+type standIn struct {
+	*httptest.Server
+	status atomic.Int32
+	calls  atomic.Int32
+}
+
+func newStandIn(t *testing.T, status int) *standIn {
+	s := &standIn{}
+	s.status.Store(int32(status))
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		status := int(s.status.Load())
+		if status == http.StatusOK {
+			w.Write([]byte(`{"completion":"hi"}`))
+			return
+		}
+		w.Header().Set("X-Amzn-ErrorType", map[int]string{
+			http.StatusTooManyRequests:    "ThrottlingException",
+			http.StatusServiceUnavailable: "ServiceUnavailableException",
+			http.StatusBadRequest:         "ValidationException",
+		}[status])
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"stand-in error"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *standIn) client() *bedrockruntime.Client {
+	return bedrockruntime.New(bedrockruntime.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(s.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+func TestFailoverClient(t *testing.T) {
+
+	primary := newStandIn(t, http.StatusTooManyRequests)
+	secondary := newStandIn(t, http.StatusOK)
+
+	now := time.Now()
+	c := NewFailoverClient([]FailoverTarget{
+		{Name: "us-east-1", Client: primary.client()},
+		{Name: "us-west-2", Client: secondary.client()},
+	}, WithCooldown(time.Minute, 4*time.Minute))
+	c.now = func() time.Time { return now }
+
+	invoke := func() error {
+		_, err := c.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{ModelId: aws.String("anthropic.claude-v2"), Body: []byte(`{}`)})
+		return err
+	}
+
+	// the primary is throttled: fail over, then stick to the secondary
+	assert.NoError(t, invoke())
+	assert.NoError(t, invoke())
+	assert.Equal(t, int32(1), primary.calls.Load())
+	assert.Equal(t, int32(2), secondary.calls.Load())
+	assert.False(t, c.Health()[0].Healthy)
+
+	// after the cooldown the primary is tried again, and is still failing
+	now = now.Add(time.Minute)
+	assert.NoError(t, invoke())
+	assert.Equal(t, int32(2), primary.calls.Load())
+	assert.Equal(t, 2, c.Health()[0].ConsecutiveFailures)
+	assert.Equal(t, now.Add(2*time.Minute), c.Health()[0].UnhealthyUntil)
+
+	// the primary recovers
+	primary.status.Store(http.StatusOK)
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, invoke())
+	assert.NoError(t, invoke())
+	assert.Equal(t, int32(4), primary.calls.Load())
+	assert.Equal(t, int32(3), secondary.calls.Load())
+	assert.True(t, c.Health()[0].Healthy)
+
+	// client errors do not fail over
+	primary.status.Store(http.StatusBadRequest)
+	assert.Error(t, invoke())
+	assert.Equal(t, int32(3), secondary.calls.Load())
+
+	// when every target fails, the last error is returned
+	primary.status.Store(http.StatusServiceUnavailable)
+	secondary.status.Store(http.StatusServiceUnavailable)
+	err := invoke()
+	assert.True(t, IsFailoverError(err))
+}
@@ -0,0 +1,176 @@
+// Package fallback provides an llms.LLM that tries several models in order,
+// moving on to the next one when a model is throttled or unavailable.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Keys added to the GenerationInfo of the generations returned by a Chain.
+const (
+	// GenerationInfoModel is the name of the model that answered.
+	GenerationInfoModel = "model"
+	// GenerationInfoAttempts is the number of models tried, including the one
+	// that answered.
+	GenerationInfoAttempts = "attempts"
+)
+
+var (
+	ErrEmptyResponse   = errors.New("empty response")
+	ErrNoModels        = errors.New("no models")
+	ErrMissingLLM      = errors.New("missing llm")
+	ErrAllModelsFailed = errors.New("all models failed")
+)
+
+// Model is one of the models of a Chain.
+type Model struct {
+	// Name identifies the model in GenerationInfo. It defaults to the model ID
+	// of the LLM types in this module.
+	Name string
+	LLM  llms.LLM
+	// AdaptPrompt, if not nil, rewrites every prompt for this model.
+	AdaptPrompt func(prompt string) string
+	// Options are added to the call options when this model is used.
+	Options []llms.CallOption
+}
+
+type Chain struct {
+	models     []Model
+	fallbackOn func(error) bool
+	maxLatency time.Duration
+}
+
+var _ llms.LLM = (*Chain)(nil)
+
+type Option func(*Chain)
+
+// WithFallbackOn sets the errors that move on to the next model. The default
+// is llm.IsFailoverError: throttling, exhausted quotas, server and connection
+// errors.
+func WithFallbackOn(fallbackOn func(error) bool) Option {
+	return func(c *Chain) {
+		c.fallbackOn = fallbackOn
+	}
+}
+
+// WithMaxLatency bounds the total time spent on a call across all the models
+// tried.
+func WithMaxLatency(d time.Duration) Option {
+	return func(c *Chain) {
+		c.maxLatency = d
+	}
+}
+
+// New returns a Chain that tries models in order.
+func New(models []Model, options ...Option) (*Chain, error) {
+
+	if len(models) == 0 {
+		return nil, ErrNoModels
+	}
+
+	c := &Chain{fallbackOn: llm.IsFailoverError}
+
+	for i, m := range models {
+		if m.LLM == nil {
+			return nil, fmt.Errorf("%w: model %d", ErrMissingLLM, i)
+		}
+		if m.Name == "" {
+			if id, ok := m.LLM.(interface{ ModelID() string }); ok {
+				m.Name = id.ModelID()
+			}
+		}
+		c.models = append(c.models, m)
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+func (c *Chain) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	r, err := c.Generate(ctx, []string{prompt}, options...)
+	if err != nil {
+		return "", err
+	}
+	if len(r) == 0 {
+		return "", ErrEmptyResponse
+	}
+	return r[0].Text, nil
+}
+
+// Generate calls the models in order until one succeeds or fails with an
+// error that does not trigger a fallback. Once a streaming model has passed a
+// chunk to the streaming function, its error is returned as is, so that the
+// output is never mixed from several models.
+func (c *Chain) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	if c.maxLatency > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.maxLatency)
+		defer cancel()
+	}
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	var errs []error
+
+	for i, m := range c.models {
+
+		streamed := false
+		modelOptions := append(options[:len(options):len(options)], m.Options...)
+		if opts.StreamingFunc != nil {
+			modelOptions = append(modelOptions, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				streamed = true
+				return opts.StreamingFunc(ctx, chunk)
+			}))
+		}
+
+		generations, err := m.LLM.Generate(ctx, c.adapt(m, prompts), modelOptions...)
+		if err == nil {
+			for _, g := range generations {
+				if g.GenerationInfo == nil {
+					g.GenerationInfo = map[string]any{}
+				}
+				g.GenerationInfo[GenerationInfoModel] = m.Name
+				g.GenerationInfo[GenerationInfoAttempts] = i + 1
+			}
+			return generations, nil
+		}
+
+		if streamed || ctx.Err() != nil || !c.fallbackOn(err) {
+			return generations, err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+	}
+
+	return nil, fmt.Errorf("%w: %w", ErrAllModelsFailed, errors.Join(errs...))
+}
+
+func (c *Chain) adapt(m Model, prompts []string) []string {
+	if m.AdaptPrompt == nil {
+		return prompts
+	}
+
+	adapted := make([]string, len(prompts))
+	for i, p := range prompts {
+		adapted[i] = m.AdaptPrompt(p)
+	}
+	return adapted
+}
+
+func (c *Chain) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, c, prompts, options...)
+}
@@ -0,0 +1,105 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type stubLLM struct {
+	id      string
+	err     error
+	chunk   string
+	prompts []string
+	delay   time.Duration
+}
+
+func (s *stubLLM) ModelID() string { return s.id }
+
+func (s *stubLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (s *stubLLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	s.prompts = append(s.prompts, prompts...)
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if s.chunk != "" && opts.StreamingFunc != nil {
+		opts.StreamingFunc(ctx, []byte(s.chunk))
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []*llms.Generation{{Text: "answer from " + s.id}}, nil
+}
+
+var throttled = &types.ThrottlingException{Message: aws.String("slow down")}
+
+func TestChainFallsBack(t *testing.T) {
+
+	claude := &stubLLM{id: "anthropic.claude-v2", err: throttled}
+	llama := &stubLLM{id: "meta.llama2-70b-chat-v1"}
+
+	chain, err := New([]Model{
+		{LLM: claude},
+		{LLM: llama, AdaptPrompt: func(p string) string { return "[INST] " + p + " [/INST]" }},
+	})
+	assert.NoError(t, err)
+
+	generations, err := chain.Generate(context.Background(), []string{"hi"})
+	assert.NoError(t, err)
+	assert.Equal(t, "answer from meta.llama2-70b-chat-v1", generations[0].Text)
+	assert.Equal(t, "meta.llama2-70b-chat-v1", generations[0].GenerationInfo[GenerationInfoModel])
+	assert.Equal(t, 2, generations[0].GenerationInfo[GenerationInfoAttempts])
+	assert.Equal(t, []string{"hi"}, claude.prompts)
+	assert.Equal(t, []string{"[INST] hi [/INST]"}, llama.prompts)
+}
+
+func TestChainErrors(t *testing.T) {
+
+	invalid := errors.New("invalid request")
+
+	chain, _ := New([]Model{{LLM: &stubLLM{id: "a", err: invalid}}, {LLM: &stubLLM{id: "b"}}})
+	_, err := chain.Generate(context.Background(), []string{"hi"})
+	assert.ErrorIs(t, err, invalid)
+
+	chain, _ = New([]Model{{LLM: &stubLLM{id: "a", err: throttled}}, {LLM: &stubLLM{id: "b", err: throttled}}})
+	_, err = chain.Generate(context.Background(), []string{"hi"})
+	assert.ErrorIs(t, err, ErrAllModelsFailed)
+	assert.ErrorAs(t, err, new(*types.ThrottlingException))
+
+	// no fallback once output has been streamed
+	chain, _ = New([]Model{{LLM: &stubLLM{id: "a", err: throttled, chunk: "partial"}}, {LLM: &stubLLM{id: "b"}}})
+	_, err = chain.Generate(context.Background(), []string{"hi"}, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error { return nil }))
+	assert.ErrorIs(t, err, throttled)
+
+	chain, _ = New([]Model{{LLM: &stubLLM{id: "a", err: throttled, delay: 50 * time.Millisecond}}, {LLM: &stubLLM{id: "b"}}}, WithMaxLatency(10*time.Millisecond))
+	_, err = chain.Generate(context.Background(), []string{"hi"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = New(nil)
+	assert.ErrorIs(t, err, ErrNoModels)
+
+	_, err = New([]Model{{LLM: &stubLLM{id: "a"}}, {Name: "b"}})
+	assert.ErrorIs(t, err, ErrMissingLLM)
+}
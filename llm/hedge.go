@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+const (
+	defaultHedgeDelay = 2 * time.Second
+
+	// hedgeWindow is the number of recent latencies per model the hedge
+	// percentile is computed from, and hedgeMinSamples the number needed
+	// before the percentile replaces the fixed delay.
+	hedgeWindow     = 200
+	hedgeMinSamples = 20
+)
+
+// HedgeMetricsRecorder is implemented by a MetricsRecorder that records
+// hedging (the metrics package does).
+type HedgeMetricsRecorder interface {
+	// RecordHedge is called for every invocation made by a HedgingClient.
+	// hedged reports whether a duplicate was sent and hedgeWon whether the
+	// duplicate's response was used.
+	RecordHedge(ctx context.Context, modelID string, hedged, hedgeWon bool)
+}
+
+// HedgingClient is a RuntimeClient that reduces tail latency: if a model has
+// not responded after the hedge delay, it sends a duplicate invocation, to
+// the same client or a hedge client in another region, uses whichever
+// successful response comes first and cancels the other invocation.
+//
+// The delay is fixed, or the observed latency percentile of the model once
+// enough invocations have been seen. Only InvokeModel is hedged; streams are
+// passed through. Duplicates of invocations made under a RateLimiter take a
+// request and the estimated tokens from its budget like the original, and
+// are not sent if the budget cannot cover them.
+type HedgingClient struct {
+	client     RuntimeClient
+	hedge      RuntimeClient
+	delay      time.Duration
+	percentile float64
+	logger     *slog.Logger
+	metrics    []HedgeMetricsRecorder
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+var _ RuntimeClient = (*HedgingClient)(nil)
+
+type HedgeOption func(*HedgingClient)
+
+// WithHedgeDelay sets how long to wait for a response before hedging (2
+// seconds by default). With WithHedgePercentile, it is used until enough
+// latencies have been observed.
+func WithHedgeDelay(d time.Duration) HedgeOption {
+	return func(h *HedgingClient) {
+		h.delay = d
+	}
+}
+
+// WithHedgePercentile hedges invocations that take longer than the p-th
+// percentile (between 0 and 1, for example 0.95) of the recent latencies of
+// the model.
+func WithHedgePercentile(p float64) HedgeOption {
+	return func(h *HedgingClient) {
+		h.percentile = p
+	}
+}
+
+// WithHedgeClient sends duplicates to client, for example a client for
+// another region, instead of the hedged client.
+func WithHedgeClient(client RuntimeClient) HedgeOption {
+	return func(h *HedgingClient) {
+		h.hedge = client
+	}
+}
+
+// WithHedgeLogger logs hedged invocations at debug level.
+func WithHedgeLogger(logger *slog.Logger) HedgeOption {
+	return func(h *HedgingClient) {
+		if logger != nil {
+			h.logger = logger
+		}
+	}
+}
+
+// WithHedgeMetrics records hedging with metrics if it implements
+// HedgeMetricsRecorder.
+func WithHedgeMetrics(metrics MetricsRecorder) HedgeOption {
+	return func(h *HedgingClient) {
+		if m, ok := metrics.(HedgeMetricsRecorder); ok {
+			h.metrics = append(h.metrics, m)
+		}
+	}
+}
+
+// NewHedgingClient returns a HedgingClient that invokes models with client.
+func NewHedgingClient(client RuntimeClient, options ...HedgeOption) *HedgingClient {
+
+	h := &HedgingClient{
+		client:    client,
+		hedge:     client,
+		delay:     defaultHedgeDelay,
+		logger:    NopLogger(),
+		latencies: map[string]*latencyWindow{},
+	}
+
+	for _, opt := range options {
+		opt(h)
+	}
+
+	return h
+}
+
+type hedgeResult struct {
+	output *bedrockruntime.InvokeModelOutput
+	err    error
+	hedge  bool
+}
+
+func (h *HedgingClient) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	modelID := aws.ToString(params.ModelId)
+	delay := h.hedgeDelay(modelID)
+
+	// cancelling ctx on return stops the invocation that lost
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	invoke := func(client RuntimeClient, hedge bool) {
+		go func() {
+			output, err := client.InvokeModel(ctx, params, optFns...)
+			results <- hedgeResult{output: output, err: err, hedge: hedge}
+		}()
+	}
+
+	start := time.Now()
+	invoke(h.client, false)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	hedged := false
+	var firstErr error
+
+	for {
+		select {
+		case <-timer.C:
+			if !reserveDuplicate(ctx) {
+				h.logger.DebugContext(ctx, "not hedging invocation, rate limit budget exhausted", slog.String("model_id", modelID))
+				continue
+			}
+			h.logger.DebugContext(ctx, "hedging invocation", slog.String("model_id", modelID), slog.Duration("delay", delay))
+			hedged = true
+			invoke(h.hedge, true)
+			pending++
+
+		case r := <-results:
+			pending--
+
+			if r.err == nil {
+				h.observe(modelID, time.Since(start))
+				for _, m := range h.metrics {
+					m.RecordHedge(ctx, modelID, hedged, r.hedge)
+				}
+				return r.output, nil
+			}
+
+			if firstErr == nil {
+				firstErr = r.err
+			}
+
+			// a failed invocation is not hedged, retrying it is up to the
+			// SDK retryer or a FailoverClient
+			if pending == 0 {
+				for _, m := range h.metrics {
+					m.RecordHedge(ctx, modelID, hedged, false)
+				}
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func (h *HedgingClient) InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+	return h.client.InvokeModelWithResponseStream(ctx, params, optFns...)
+}
+
+// hedgeDelay returns how long to wait for a response of modelID before
+// hedging.
+func (h *HedgingClient) hedgeDelay(modelID string) time.Duration {
+
+	if h.percentile <= 0 {
+		return h.delay
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.latencies[modelID]
+	if !ok || w.n < hedgeMinSamples {
+		return h.delay
+	}
+
+	return w.percentile(h.percentile)
+}
+
+func (h *HedgingClient) observe(modelID string, latency time.Duration) {
+
+	if h.percentile <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.latencies[modelID]
+	if !ok {
+		w = &latencyWindow{}
+		h.latencies[modelID] = w
+	}
+	w.add(latency)
+}
+
+// latencyWindow holds the most recent latencies of a model.
+type latencyWindow struct {
+	samples [hedgeWindow]time.Duration
+	n       int
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeWindow
+	if w.n < hedgeWindow {
+		w.n++
+	}
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+
+	sorted := make([]time.Duration, w.n)
+	copy(sorted, w.samples[:w.n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(p*float64(w.n))) - 1
+	i = min(max(i, 0), w.n-1)
+
+	return sorted[i]
+}
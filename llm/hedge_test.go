@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowClient answers InvokeModel with body after delay, unless ctx is
+// cancelled first.
+type slowClient struct {
+	RuntimeClient
+	delay time.Duration
+	body  string
+	err   error
+
+	mu        sync.Mutex
+	calls     int
+	cancelled int
+}
+
+func (c *slowClient) InvokeModel(ctx context.Context, _ *bedrockruntime.InvokeModelInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(c.delay):
+		if c.err != nil {
+			return nil, c.err
+		}
+		return &bedrockruntime.InvokeModelOutput{Body: []byte(c.body)}, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.cancelled++
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *slowClient) counts() (calls, cancelled int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls, c.cancelled
+}
+
+type hedgeRecorder struct {
+	MetricsRecorder
+	hedged, won []bool
+}
+
+func (r *hedgeRecorder) RecordHedge(_ context.Context, _ string, hedged, hedgeWon bool) {
+	r.hedged = append(r.hedged, hedged)
+	r.won = append(r.won, hedgeWon)
+}
+
+func invokeModel(client RuntimeClient) (string, error) {
+	out, err := client.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)})
+	if err != nil {
+		return "", err
+	}
+	return string(out.Body), nil
+}
+
+func TestHedgingClient(t *testing.T) {
+
+	primary := &slowClient{delay: time.Second, body: "primary"}
+	other := &slowClient{delay: 0, body: "other"}
+	metrics := &hedgeRecorder{}
+
+	h := NewHedgingClient(primary, WithHedgeDelay(10*time.Millisecond), WithHedgeClient(other), WithHedgeMetrics(metrics))
+
+	// the primary is slow: the duplicate answers and the primary is cancelled
+	body, err := invokeModel(h)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", body)
+
+	assert.Eventually(t, func() bool {
+		_, cancelled := primary.counts()
+		return cancelled == 1
+	}, time.Second, time.Millisecond)
+
+	// the primary answers before the delay: no duplicate
+	primary.delay = 0
+	body, err = invokeModel(h)
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", body)
+
+	calls, _ := other.counts()
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, []bool{true, false}, metrics.hedged)
+	assert.Equal(t, []bool{true, false}, metrics.won)
+}
+
+func TestHedgingClientErrors(t *testing.T) {
+
+	boom := errors.New("boom")
+
+	// a failed hedged invocation waits for the other one
+	primary := &slowClient{delay: 50 * time.Millisecond, err: boom}
+	other := &slowClient{delay: 100 * time.Millisecond, body: "other"}
+	h := NewHedgingClient(primary, WithHedgeDelay(10*time.Millisecond), WithHedgeClient(other))
+
+	body, err := invokeModel(h)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", body)
+
+	// a failure before the delay is returned as is
+	primary.delay = 0
+	_, err = invokeModel(h)
+	assert.ErrorIs(t, err, boom)
+
+	calls, _ := other.counts()
+	assert.Equal(t, 1, calls)
+}
+
+func TestHedgePercentile(t *testing.T) {
+
+	h := NewHedgingClient(&slowClient{}, WithHedgeDelay(time.Second), WithHedgePercentile(0.9))
+
+	for i := 1; i < hedgeMinSamples; i++ {
+		h.observe(ModelClaudeV2, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, time.Second, h.hedgeDelay(ModelClaudeV2))
+
+	h.observe(ModelClaudeV2, hedgeMinSamples*time.Millisecond)
+	assert.Equal(t, 18*time.Millisecond, h.hedgeDelay(ModelClaudeV2))
+	assert.Equal(t, time.Second, h.hedgeDelay(ModelClaudeV21))
+}
+
+func TestHedgingClientRateLimit(t *testing.T) {
+
+	primary := &slowClient{delay: 50 * time.Millisecond, body: "primary"}
+	h := NewHedgingClient(primary, WithHedgeDelay(time.Millisecond))
+
+	l := NewRateLimiter(WithDefaultRateLimit(RateLimit{TokensPerMinute: 1000}), WithRejectWhenLimited())
+	fakeClock(l)
+
+	invoke := func(tokens int) {
+		r, err := l.Reserve(context.Background(), ModelClaudeV2, tokens)
+		assert.NoError(t, err)
+
+		ctx := ContextWithReservation(context.Background(), r)
+		_, err = h.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)})
+		assert.NoError(t, err)
+	}
+
+	// the duplicate takes the same tokens from the budget
+	invoke(400)
+	calls, _ := primary.counts()
+	assert.Equal(t, 2, calls)
+
+	// no duplicate when the budget cannot cover it
+	invoke(200)
+	calls, _ = primary.counts()
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/tmc/langchaingo/callbacks"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Invoker invokes a model on behalf of the model packages, which only supply
+// the request body and the decoding of the response. It logs the payload,
+// traces the invocation, takes a reservation from the rate limiter, reports
+// retries to the callbacks handler and records the invocation metrics.
+type Invoker struct {
+	Client    RuntimeClient
+	ModelID   string
+	Callbacks callbacks.Handler
+	// Logger defaults to NopLogger and Tracer to a no-op tracer.
+	Logger  *slog.Logger
+	Tracer  trace.Tracer
+	Metrics MetricsRecorder
+	Limiter *RateLimiter
+}
+
+// Invocation is a single request to a model.
+type Invocation struct {
+	Body []byte
+	// Span describes the request on the span of the invocation. Its Operation
+	// is also used for the invocation metrics.
+	Span SpanParams
+	// EstimatedTokens is reserved with the rate limiter before invoking.
+	EstimatedTokens int
+}
+
+// ResponseDecoder decodes the body of a model response and returns the reason
+// the model stopped.
+type ResponseDecoder func(body []byte) (stopReason string, err error)
+
+// Invoke sends inv and passes the response body to decode. The token usage is
+// read from the response headers.
+func (i Invoker) Invoke(ctx context.Context, inv Invocation, decode ResponseDecoder) error {
+
+	i = i.withDefaults()
+
+	LogPayload(ctx, i.Logger, i.ModelID, inv.Body)
+
+	ctx, span := StartSpan(ctx, i.Tracer, inv.Span)
+
+	reservation, err := i.Limiter.Reserve(ctx, i.ModelID, inv.EstimatedTokens)
+	if err != nil {
+		EndSpan(span, Usage{}, "", err)
+		return err
+	}
+
+	var attempts Attempts
+	start := time.Now()
+
+	output, err := i.Client.InvokeModel(i.context(ctx, reservation), &bedrockruntime.InvokeModelInput{
+		Body:        inv.Body,
+		ModelId:     aws.String(i.ModelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+	}, i.clientOptions(ctx, &attempts)...)
+
+	if err != nil {
+		reservation.Settle(Usage{})
+		ObserveInvocation(ctx, i.Logger, i.Metrics, NewInvocationStats(i.ModelID, inv.Span.Operation, start, nil, &attempts, Usage{}, err))
+		EndSpan(span, Usage{}, "", err)
+		return err
+	}
+
+	usage := UsageFromMetadata(output.ResultMetadata)
+	reservation.Settle(usage)
+
+	ObserveInvocation(ctx, i.Logger, i.Metrics, NewInvocationStats(i.ModelID, inv.Span.Operation, start, &output.ResultMetadata, &attempts, usage, nil))
+
+	stopReason, err := decode(output.Body)
+	EndSpan(span, usage, stopReason, err)
+
+	return err
+}
+
+// Stream sends inv and returns its response stream, read with cfg.Decode. The
+// token usage is taken from the usage events of the stream. cfg.Handler is
+// wrapped to report chunks to the callbacks handler, the time to the first
+// token and a span event; cfg.Done is called after the invocation has been
+// observed.
+func (i Invoker) Stream(ctx context.Context, inv Invocation, cfg StreamConfig) (*Stream, error) {
+
+	i = i.withDefaults()
+
+	LogPayload(ctx, i.Logger, i.ModelID, inv.Body)
+
+	ctx, span := StartSpan(ctx, i.Tracer, inv.Span)
+
+	handler := cfg.Handler
+	if handler == nil {
+		handler = func(context.Context, []byte) error { return nil }
+	}
+	handler = CallbackStreamingFunc(i.Callbacks, handler)
+	handler = MeasureStreamingFunc(i.Metrics, i.ModelID, handler)
+	handler = TraceStreamingFunc(span, handler)
+
+	reservation, err := i.Limiter.Reserve(ctx, i.ModelID, inv.EstimatedTokens)
+	if err != nil {
+		EndStreamSpan(span, Usage{}, "", err)
+		return nil, err
+	}
+
+	var attempts Attempts
+	start := time.Now()
+
+	output, err := i.Client.InvokeModelWithResponseStream(i.context(ctx, reservation), &bedrockruntime.InvokeModelWithResponseStreamInput{
+		Body:        inv.Body,
+		ModelId:     aws.String(i.ModelID),
+		ContentType: aws.String("application/json"),
+	}, i.clientOptions(ctx, &attempts)...)
+
+	if err != nil {
+		reservation.Settle(Usage{})
+		ObserveInvocation(ctx, i.Logger, i.Metrics, NewInvocationStats(i.ModelID, inv.Span.Operation, start, nil, &attempts, Usage{}, err))
+		EndStreamSpan(span, Usage{}, "", err)
+		return nil, err
+	}
+
+	done := cfg.Done
+
+	return NewStream(ctx, output.GetStream(), StreamConfig{
+		Decode:  cfg.Decode,
+		Handler: handler,
+		Logger:  i.Logger,
+		Done: func(text, stopReason string, usage Usage, err error) {
+			reservation.Settle(usage)
+			ObserveInvocation(ctx, i.Logger, i.Metrics, NewInvocationStats(i.ModelID, inv.Span.Operation, start, &output.ResultMetadata, &attempts, usage, err))
+			EndStreamSpan(span, usage, stopReason, err)
+			if done != nil {
+				done(text, stopReason, usage, err)
+			}
+		},
+	}), nil
+}
+
+func (i Invoker) withDefaults() Invoker {
+	if i.Logger == nil {
+		i.Logger = NopLogger()
+	}
+	if i.Tracer == nil {
+		i.Tracer = Tracer(nil)
+	}
+	return i
+}
+
+// context returns the context of the Bedrock call, which carries the
+// callbacks handler and the rate limiter reservation for the clients
+// wrapping the SDK client.
+func (i Invoker) context(ctx context.Context, reservation *Reservation) context.Context {
+	return ContextWithReservation(ContextWithCallbacks(ctx, i.Callbacks), reservation)
+}
+
+func (i Invoker) clientOptions(ctx context.Context, attempts *Attempts) []func(*bedrockruntime.Options) {
+	return append(RetryCallbacks(ctx, i.Callbacks), attempts.ClientOption())
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+type statsRecorder struct {
+	MetricsRecorder
+	stats []InvocationStats
+}
+
+func (r *statsRecorder) RecordInvocation(_ context.Context, stats InvocationStats) {
+	r.stats = append(r.stats, stats)
+}
+
+func (r *statsRecorder) RecordTimeToFirstToken(context.Context, string, time.Duration) {}
+
+// eventStream encodes chunks as the event stream of a streamed response.
+func eventStream(t *testing.T, chunks ...string) []byte {
+
+	encoder := eventstream.NewEncoder()
+	var buf bytes.Buffer
+
+	for _, chunk := range chunks {
+
+		payload, err := json.Marshal(struct {
+			Bytes []byte `json:"bytes"`
+		}{[]byte(chunk)})
+		assert.Nil(t, err)
+
+		var msg eventstream.Message
+		msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+		msg.Headers.Set(":event-type", eventstream.StringValue("chunk"))
+		msg.Headers.Set(":content-type", eventstream.StringValue("application/json"))
+		msg.Payload = payload
+
+		assert.Nil(t, encoder.Encode(&buf, msg))
+	}
+
+	return buf.Bytes()
+}
+
+func testInvoker(t *testing.T, handler http.HandlerFunc, metrics MetricsRecorder) Invoker {
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := bedrockruntime.New(bedrockruntime.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	return Invoker{Client: client, ModelID: ModelClaudeV2, Metrics: metrics}
+}
+
+type completion struct {
+	Completion string `json:"completion"`
+	StopReason string `json:"stop_reason"`
+}
+
+func TestInvokerInvoke(t *testing.T) {
+
+	var body []byte
+	metrics := &statsRecorder{}
+
+	invoker := testInvoker(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Amzn-Bedrock-Input-Token-Count", "3")
+		w.Header().Set("X-Amzn-Bedrock-Output-Token-Count", "2")
+		w.Write([]byte(`{"completion":" Hi!","stop_reason":"stop_sequence"}`))
+	}, metrics)
+
+	var resp completion
+	err := invoker.Invoke(context.Background(), Invocation{Body: []byte(`{"prompt":"hello"}`), Span: SpanParams{Operation: OperationTextCompletion}}, func(body []byte) (string, error) {
+		err := json.Unmarshal(body, &resp)
+		return resp.StopReason, err
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, `{"prompt":"hello"}`, string(body))
+	assert.Equal(t, completion{Completion: " Hi!", StopReason: "stop_sequence"}, resp)
+
+	assert.Equal(t, 1, len(metrics.stats))
+	assert.Equal(t, OperationTextCompletion, metrics.stats[0].Operation)
+	assert.Equal(t, Usage{InputTokens: 3, OutputTokens: 2}, metrics.stats[0].Usage)
+	assert.Nil(t, metrics.stats[0].Err)
+}
+
+func TestInvokerInvokeError(t *testing.T) {
+
+	metrics := &statsRecorder{}
+
+	invoker := testInvoker(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "ValidationException")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"stand-in error"}`))
+	}, metrics)
+
+	decoded := false
+	err := invoker.Invoke(context.Background(), Invocation{Body: []byte(`{}`)}, func([]byte) (string, error) {
+		decoded = true
+		return "", nil
+	})
+	assert.Error(t, err)
+	assert.False(t, decoded)
+
+	assert.Equal(t, 1, len(metrics.stats))
+	assert.Equal(t, err, metrics.stats[0].Err)
+}
+
+func TestInvokerStream(t *testing.T) {
+
+	metrics := &statsRecorder{}
+
+	invoker := testInvoker(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/invoke-with-response-stream"))
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(eventStream(t,
+			`{"completion":"Hel"}`,
+			`{"completion":"lo","stop_reason":"stop_sequence","amazon-bedrock-invocationMetrics":{"inputTokenCount":3,"outputTokenCount":2}}`,
+		))
+	}, metrics)
+
+	var chunks []string
+	var doneText string
+	var doneUsage Usage
+
+	stream, err := invoker.Stream(context.Background(), Invocation{Body: []byte(`{}`), Span: SpanParams{Operation: OperationTextCompletion}}, StreamConfig{
+		Decode: JSONChunkDecoder(
+			func(c *completion) string { return c.Completion },
+			func(c *completion) string { return c.StopReason },
+		),
+		Handler: func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		},
+		Done: func(text, _ string, usage Usage, _ error) {
+			doneText, doneUsage = text, usage
+		},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, stream.Drain())
+
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+	assert.Equal(t, "Hello", doneText)
+	assert.Equal(t, Usage{InputTokens: 3, OutputTokens: 2}, doneUsage)
+	assert.Equal(t, "stop_sequence", stream.StopReason())
+
+	assert.Equal(t, 1, len(metrics.stats))
+	assert.Equal(t, Usage{InputTokens: 3, OutputTokens: 2}, metrics.stats[0].Usage)
+}
+
+func TestInvokerStreamError(t *testing.T) {
+
+	metrics := &statsRecorder{}
+
+	invoker := testInvoker(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "ValidationException")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"stand-in error"}`))
+	}, metrics)
+
+	stream, err := invoker.Stream(context.Background(), Invocation{Body: []byte(`{}`)}, StreamConfig{})
+	assert.Nil(t, stream)
+	assert.Error(t, err)
+
+	var apiErr interface{ ErrorCode() string }
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "ValidationException", apiErr.ErrorCode())
+
+	assert.Equal(t, 1, len(metrics.stats))
+	assert.Equal(t, err, metrics.stats[0].Err)
+}
@@ -4,24 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/abhirockzz/amazon-bedrock-go-inference-params/llama"
 	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/schema"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrEmptyResponse = errors.New("empty response")
 
 type LLM struct {
 	CallbacksHandler callbacks.Handler
-	brc              *bedrockruntime.Client
+	brc              llm.RuntimeClient
 	modelID          string
+	baseModelID      string
+	logger           *slog.Logger
+	tracer           trace.Tracer
+	metrics          llm.MetricsRecorder
+	tokens           llm.TokenCounter
+	limiter          *llm.RateLimiter
 }
 
 var (
@@ -39,34 +43,53 @@ func New(region string, options ...llm.ConfigOption) (*LLM, error) {
 		return nil, ErrMissingRegion
 	}
 
-	llamaLLM := &LLM{modelID: defaultModelID}
+	llamaLLM := &LLM{modelID: defaultModelID, logger: llm.NopLogger()}
 
 	opts := &llm.ConfigOptions{}
 	for _, opt := range options {
 		opt(opts)
 	}
 
-	if opts.BedrockRuntimeClient == nil {
-		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
-		if err != nil {
-			return nil, err
-		}
-
-		llamaLLM.brc = bedrockruntime.NewFromConfig(cfg)
-	} else {
-		llamaLLM.brc = opts.BedrockRuntimeClient
+	brc, err := llm.NewRuntimeClient(context.Background(), region, opts)
+	if err != nil {
+		return nil, err
 	}
 
+	llamaLLM.brc = brc
+
 	if opts.ModelID != "" {
 		llamaLLM.modelID = opts.ModelID
 	}
 
+	llamaLLM.baseModelID = llm.ResolveBaseModel(llamaLLM.modelID, opts)
+
+	if err := llm.CheckModel(llamaLLM.baseModelID, llm.ProviderMeta, llm.ModalityText); err != nil {
+		return nil, err
+	}
+
+	if opts.Logger != nil {
+		llamaLLM.logger = opts.Logger
+	}
+
+	llamaLLM.tracer = llm.Tracer(opts.TracerProvider)
+	llamaLLM.metrics = opts.Metrics
+
+	llamaLLM.tokens = opts.TokenCounter
+	if llamaLLM.tokens == nil {
+		llamaLLM.tokens = llm.TokenCounterFor(llamaLLM.baseModelID)
+	}
+
+	llamaLLM.limiter = opts.RateLimiter
+
 	return llamaLLM, nil
 }
 
 func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
 	r, err := o.Generate(ctx, []string{prompt}, options...)
 	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
 		return "", err
 	}
 	if len(r) == 0 {
@@ -76,7 +99,7 @@ func (o *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOptio
 }
 
 const (
-	defaultModelID = "meta.llama2-13b-chat-v1" //https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+	defaultModelID = llm.ModelLlama2Chat13B
 )
 
 func (o *LLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
@@ -84,102 +107,147 @@ func (o *LLM) Generate(ctx context.Context, prompts []string, options ...llms.Ca
 		o.CallbacksHandler.HandleLLMStart(ctx, prompts)
 	}
 
-	opts := &llms.CallOptions{}
-	for _, opt := range options {
-		opt(opts)
-	}
-
-	payload := llama.Request{
-		Prompt:      prompts[0],
-		MaxGenLen:   opts.MaxTokens,
-		Temperature: opts.Temperature,
-		TopP:        opts.TopP,
+	generations, err := o.generate(ctx, prompts, options...)
+	if err != nil {
+		if o.CallbacksHandler != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+		}
+		return generations, err
 	}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
 	}
+	return generations, nil
+}
 
-	log.Println("payload\n", string(payloadBytes))
+func (o *LLM) generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
 
-	var resp llama.Response
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
 
 	if opts.StreamingFunc != nil {
 
-		resp, err = o.invokeAsyncAndGetResponse(payloadBytes, opts.StreamingFunc)
+		stream, err := o.stream(ctx, prompts[0], opts, nil)
 		if err != nil {
 			return nil, err
 		}
 
-	} else {
-		resp, err = o.invokeAndGetResponse(payloadBytes)
-		if err != nil {
-			return nil, err
+		if err := stream.Drain(); err != nil {
+			return []*llms.Generation{{Text: stream.Text()}}, err
 		}
+
+		return []*llms.Generation{
+			{Text: stream.Text(), StopReason: stream.StopReason()},
+		}, nil
 	}
 
-	generations := []*llms.Generation{
-		{Text: resp.GetResponseString()},
+	err := llm.ValidateCallOptions(o.baseModelID, opts, false)
+	if err != nil {
+		return nil, err
 	}
 
-	if o.CallbacksHandler != nil {
-		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
+	payloadBytes, err := o.request(prompts[0], opts)
+	if err != nil {
+		return nil, err
 	}
-	return generations, nil
-}
 
-func (o *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
-	return llms.GeneratePrompt(ctx, o, prompts, options...)
-}
+	var resp response
 
-func (o *LLM) GetNumTokens(text string) int {
-	return llms.CountTokens("gpt4", text)
+	err = o.invoker().Invoke(ctx, o.invocation(payloadBytes, prompts[0], opts), func(body []byte) (string, error) {
+		err := json.Unmarshal(body, &resp)
+		return resp.StopReason, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	generations := []*llms.Generation{
+		{Text: resp.GetResponseString(), StopReason: resp.StopReason},
+	}
+
+	return generations, nil
 }
 
-func (o *LLM) invokeAndGetResponse(payloadBytes []byte) (llama.Response, error) {
+func (o *LLM) request(prompt string, opts *llms.CallOptions) ([]byte, error) {
 
-	output, err := o.brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(o.modelID),
-		ContentType: aws.String("application/json"),
-		Accept:      aws.String("application/json"),
-	})
+	payload := llama.Request{
+		Prompt:      prompt,
+		MaxGenLen:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+	}
 
+	err := llm.CheckPromptSize(o.tokens, o.baseModelID, payload.Prompt, opts.MaxTokens)
 	if err != nil {
-		return llama.Response{}, err
+		return nil, err
 	}
 
-	var resp llama.Response
+	return json.Marshal(payload)
+}
 
-	err = json.Unmarshal(output.Body, &resp)
+func (o *LLM) spanParams(opts *llms.CallOptions) llm.SpanParams {
+	return llm.SpanParams{
+		Operation:   llm.OperationTextCompletion,
+		ModelID:     o.modelID,
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+	}
+}
 
-	if err != nil {
-		return llama.Response{}, err
+// invocation returns the invocation sending body, the request for prompt
+// with opts.
+func (o *LLM) invocation(body []byte, prompt string, opts *llms.CallOptions) llm.Invocation {
+	return llm.Invocation{
+		Body:            body,
+		Span:            o.spanParams(opts),
+		EstimatedTokens: o.tokens.CountTokens(prompt) + opts.MaxTokens,
 	}
+}
 
-	return resp, nil
+func (o *LLM) invoker() llm.Invoker {
+	return llm.Invoker{
+		Client:    o.brc,
+		ModelID:   o.modelID,
+		Callbacks: o.CallbacksHandler,
+		Logger:    o.logger,
+		Tracer:    o.tracer,
+		Metrics:   o.metrics,
+		Limiter:   o.limiter,
+	}
 }
 
-func (o *LLM) invokeAsyncAndGetResponse(payloadBytes []byte, handler func(ctx context.Context, chunk []byte) error) (llama.Response, error) {
+func (o *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, o, prompts, options...)
+}
 
-	output, err := o.brc.InvokeModelWithResponseStream(context.Background(), &bedrockruntime.InvokeModelWithResponseStreamInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(o.modelID),
-		ContentType: aws.String("application/json"),
-	})
+// RenderRequest returns the request body sent to Bedrock for prompt with
+// options.
+func (o *LLM) RenderRequest(prompt string, options ...llms.CallOption) ([]byte, error) {
 
-	if err != nil {
-		return llama.Response{}, err
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
 	}
 
-	var resp llama.Response
+	return o.request(prompt, opts)
+}
 
-	resp, err = ProcessStreamingOutput(output, handler)
+// ModelID returns the Bedrock model identifier used for invocations.
+func (o *LLM) ModelID() string {
+	return o.modelID
+}
 
-	if err != nil {
-		return llama.Response{}, err
-	}
+func (o *LLM) GetNumTokens(text string) int {
+	return o.tokens.CountTokens(text)
+}
 
-	return resp, nil
+// response is the model response together with the stop reason the inference
+// parameters type does not carry.
+type response struct {
+	llama.Response
+	StopReason string `json:"stop_reason"`
 }
@@ -1,45 +1,78 @@
 package llama
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 
 	"github.com/abhirockzz/amazon-bedrock-go-inference-params/llama"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/tmc/langchaingo/llms"
 )
 
+// ProcessStreamingOutput reads the stream to the end, passing every chunk to
+// handler. If the stream fails, including because handler returned an error,
+// the stream is closed and a *llm.StreamError is returned together with the
+// text received so far. Handler errors are also wrapped in
+// llm.ErrStreamAborted.
 func ProcessStreamingOutput(output *bedrockruntime.InvokeModelWithResponseStreamOutput, handler func(ctx context.Context, chunk []byte) error) (llama.Response, error) {
+	text, err := llm.ProcessStreamingOutput(context.Background(), output, decodeChunk, handler, llm.NopLogger())
+	return llama.Response{Generation: text}, err
+}
+
+// Stream starts a streamed completion of prompt. The caller must close the
+// returned stream. llms.WithStreamingFunc, if given, is called with every
+// piece of text as well.
+func (o *LLM) Stream(ctx context.Context, prompt string, options ...llms.CallOption) (*llm.Stream, error) {
 
-	var combinedResult string
-	resp := llama.Response{}
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
 
-	for event := range output.GetStream().Events() {
-		switch v := event.(type) {
-		case *types.ResponseStreamMemberChunk:
+	if o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMStart(ctx, []string{prompt})
+	}
 
-			//fmt.Println("payload", string(v.Value.Bytes))
+	stream, err := o.stream(ctx, prompt, opts, func(text, stopReason string, _ llm.Usage, err error) {
+		if o.CallbacksHandler == nil {
+			return
+		}
+		if err != nil {
+			o.CallbacksHandler.HandleLLMError(ctx, err)
+			return
+		}
+		o.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{{{Text: text, StopReason: stopReason}}}})
+	})
 
-			var resp llama.Response
-			err := json.NewDecoder(bytes.NewReader(v.Value.Bytes)).Decode(&resp)
-			if err != nil {
-				return resp, err
-			}
+	if err != nil && o.CallbacksHandler != nil {
+		o.CallbacksHandler.HandleLLMError(ctx, err)
+	}
 
-			handler(context.Background(), []byte(resp.Generation))
-			combinedResult += resp.Generation
+	return stream, err
+}
 
-		case *types.UnknownUnionMember:
-			fmt.Println("unknown tag:", v.Tag)
+// stream invokes the model and returns its response stream. done, if not nil,
+// is called once the stream has ended.
+func (o *LLM) stream(ctx context.Context, prompt string, opts *llms.CallOptions, done func(text, stopReason string, usage llm.Usage, err error)) (*llm.Stream, error) {
 
-		default:
-			fmt.Println("union is nil or unknown type")
-		}
+	err := llm.ValidateCallOptions(o.baseModelID, opts, true)
+	if err != nil {
+		return nil, err
 	}
 
-	resp.Generation = combinedResult
+	payloadBytes, err := o.request(prompt, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	return resp, nil
+	return o.invoker().Stream(ctx, o.invocation(payloadBytes, prompt, opts), llm.StreamConfig{
+		Decode:  decodeChunk,
+		Handler: opts.StreamingFunc,
+		Done:    done,
+	})
 }
+
+var decodeChunk = llm.JSONChunkDecoder(
+	func(r *response) string { return r.Generation },
+	func(r *response) string { return r.StopReason },
+)
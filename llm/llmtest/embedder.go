@@ -0,0 +1,192 @@
+package llmtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/embeddings"
+)
+
+const defaultDimensions = 1536
+
+var ErrInvalidDimensions = errors.New("dimensions must be positive")
+
+// EmbedCall is a recorded call of an Embedder. Query is true for EmbedQuery.
+type EmbedCall struct {
+	Texts []string
+	Query bool
+}
+
+// Embedder is a fake of amazontitan.TitanEmbedder. Its vectors are derived
+// from a hash of the text, so equal texts get equal vectors and different
+// texts nearly orthogonal ones, and they have unit length. Texts of any
+// length are embedded whole: the oversize handling of TitanEmbedder, such as
+// SplitLongTexts and Oversize, is not emulated.
+type Embedder struct {
+	// CallbacksHandler receives, if it implements llm.EmbeddingHandler,
+	// embedding start, end and error events.
+	CallbacksHandler callbacks.Handler
+	StripNewLines    bool
+
+	modelID    string
+	dimensions int
+
+	mu    sync.Mutex
+	errs  []error
+	calls []EmbedCall
+}
+
+var _ embeddings.Embedder = (*Embedder)(nil)
+
+// NewEmbedder creates an Embedder of vectors with the given number of
+// dimensions (1536, as for Titan, if zero). Of the llm.ConfigOption values,
+// the model option applies.
+func NewEmbedder(dimensions int, options ...llm.ConfigOption) (*Embedder, error) {
+
+	if dimensions == 0 {
+		dimensions = defaultDimensions
+	}
+	if dimensions < 0 {
+		return nil, ErrInvalidDimensions
+	}
+
+	opts := &llm.ConfigOptions{ModelID: llm.ModelTitanEmbedText}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	baseModelID := llm.ResolveBaseModel(opts.ModelID, opts)
+	if err := llm.CheckModel(baseModelID, llm.ProviderOf(baseModelID), llm.ModalityEmbedding); err != nil {
+		return nil, err
+	}
+
+	return &Embedder{modelID: opts.ModelID, dimensions: dimensions}, nil
+}
+
+// FailNext makes the next calls fail with errs, one call per error.
+func (e *Embedder) FailNext(errs ...error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.errs = append(e.errs, errs...)
+}
+
+// Calls returns the calls made so far, in order.
+func (e *Embedder) Calls() []EmbedCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]EmbedCall(nil), e.calls...)
+}
+
+func (e *Embedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	texts = embeddings.MaybeRemoveNewLines(texts, e.StripNewLines)
+	return e.embed(ctx, texts, false)
+}
+
+func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if e.StripNewLines {
+		text = strings.ReplaceAll(text, "\n", " ")
+	}
+
+	emb, err := e.embed(ctx, []string{text}, true)
+	if err != nil {
+		return nil, err
+	}
+	return emb[0], nil
+}
+
+func (e *Embedder) embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+
+	h, _ := e.CallbacksHandler.(llm.EmbeddingHandler)
+	if h != nil {
+		h.HandleEmbeddingStart(ctx, texts)
+	}
+
+	if err := e.next(texts, query); err != nil {
+		if h != nil {
+			h.HandleEmbeddingError(ctx, err)
+		}
+		return nil, err
+	}
+
+	emb := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		emb = append(emb, Vector(text, e.dimensions))
+	}
+
+	if h != nil {
+		h.HandleEmbeddingEnd(ctx, texts, emb)
+	}
+	return emb, nil
+}
+
+// next records the call and returns its injected error, if any.
+func (e *Embedder) next(texts []string, query bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.calls = append(e.calls, EmbedCall{Texts: append([]string(nil), texts...), Query: query})
+
+	if len(e.errs) == 0 {
+		return nil
+	}
+
+	err := e.errs[0]
+	e.errs = e.errs[1:]
+	return err
+}
+
+// ModelID returns the model set with llm.WithModel.
+func (e *Embedder) ModelID() string {
+	return e.modelID
+}
+
+// Parameters returns the settings that influence the vectors produced by e.
+func (e *Embedder) Parameters() map[string]string {
+	return map[string]string{
+		"strip_new_lines": strconv.FormatBool(e.StripNewLines),
+		"dimensions":      strconv.Itoa(e.dimensions),
+	}
+}
+
+// Vector returns the unit vector an Embedder produces for text.
+func Vector(text string, dimensions int) []float32 {
+
+	v := make([]float32, dimensions)
+
+	var block [sha256.Size]byte
+	var counter [8]byte
+	var sum float64
+
+	for i := range v {
+		if i%(sha256.Size/4) == 0 {
+			binary.BigEndian.PutUint64(counter[:], uint64(i))
+			h := sha256.New()
+			h.Write(counter[:])
+			h.Write([]byte(text))
+			h.Sum(block[:0])
+		}
+
+		word := binary.BigEndian.Uint32(block[(i%(sha256.Size/4))*4:])
+		x := float64(word)/math.MaxUint32*2 - 1
+		v[i] = float32(x)
+		sum += x * x
+	}
+
+	if norm := math.Sqrt(sum); norm > 0 {
+		for i := range v {
+			v[i] = float32(float64(v[i]) / norm)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,262 @@
+// Package llmtest provides scriptable fakes of the LLM and embedder types in
+// this module, for unit testing code built on them without AWS.
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+var (
+	// ErrNoResponse is returned when an LLM is called more often than it has
+	// scripted responses.
+	ErrNoResponse    = errors.New("no scripted response")
+	ErrEmptyResponse = errors.New("empty response")
+)
+
+// Response is a scripted LLM response.
+type Response struct {
+	Text string
+	// Chunks are passed to the streaming function, in order. They default to
+	// Text as a single chunk, and Text defaults to the chunks joined.
+	Chunks     []string
+	StopReason string
+	// FunctionCall is returned in the generation message, as by LLMs that
+	// support llms.WithFunctions.
+	FunctionCall *schema.FunctionCall
+	// Err is returned instead of a response or, when streaming, after the
+	// chunks have been passed to the streaming function.
+	Err error
+}
+
+// Call is a recorded call of an LLM.
+type Call struct {
+	Prompt string
+	// Prefill is the prefill passed to GeneratePrefilled.
+	Prefill string
+	Options llms.CallOptions
+}
+
+// LLM is a fake of claude.LLM and the other LLM types in this module. It
+// handles options like them: the model set with llm.WithModel is reported by
+// ModelID, its limits are checked (and its context window, with a counter set
+// with llm.WithTokenCounter), only the first prompt is answered, streaming
+// functions receive the response in chunks, and the callbacks handler is
+// notified.
+type LLM struct {
+	CallbacksHandler callbacks.Handler
+
+	modelID     string
+	baseModelID string
+	tokens      llm.TokenCounter
+
+	mu        sync.Mutex
+	responses []Response
+	respond   func(prompt string, opts llms.CallOptions) Response
+	calls     []Call
+}
+
+var _ llms.LLM = (*LLM)(nil)
+
+// NewLLM creates an LLM answering with responses, in order. Of the
+// llm.ConfigOption values, the model and token counter options apply.
+func NewLLM(responses []Response, options ...llm.ConfigOption) (*LLM, error) {
+
+	opts := &llm.ConfigOptions{ModelID: llm.ModelClaudeV2}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	f := &LLM{
+		modelID:     opts.ModelID,
+		baseModelID: llm.ResolveBaseModel(opts.ModelID, opts),
+		tokens:      opts.TokenCounter,
+		responses:   responses,
+	}
+
+	if err := llm.CheckModel(f.baseModelID, llm.ProviderOf(f.baseModelID), llm.ModalityText); err != nil {
+		return nil, err
+	}
+
+	if f.tokens == nil {
+		f.tokens = llm.TokenCounterFor(f.baseModelID)
+	}
+
+	return f, nil
+}
+
+// Respond adds responses to the script.
+func (f *LLM) Respond(responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, responses...)
+}
+
+// RespondWith answers calls with fn once the scripted responses are used up.
+func (f *LLM) RespondWith(fn func(prompt string, opts llms.CallOptions) Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.respond = fn
+}
+
+// Calls returns the calls made so far, in order.
+func (f *LLM) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+func (f *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	r, err := f.Generate(ctx, []string{prompt}, options...)
+	if err != nil {
+		if len(r) > 0 {
+			return r[0].Text, err
+		}
+		return "", err
+	}
+	if len(r) == 0 {
+		return "", ErrEmptyResponse
+	}
+	return r[0].Text, nil
+}
+
+func (f *LLM) Generate(ctx context.Context, prompts []string, options ...llms.CallOption) ([]*llms.Generation, error) {
+	return f.generateWithCallbacks(ctx, prompts, "", options)
+}
+
+// GeneratePrefilled works like claude.LLM.GeneratePrefilled: the text of the
+// returned generation is prefill followed by the scripted response.
+func (f *LLM) GeneratePrefilled(ctx context.Context, prompt, prefill string, options ...llms.CallOption) ([]*llms.Generation, error) {
+	return f.generateWithCallbacks(ctx, []string{prompt}, prefill, options)
+}
+
+func (f *LLM) generateWithCallbacks(ctx context.Context, prompts []string, prefill string, options []llms.CallOption) ([]*llms.Generation, error) {
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleLLMStart(ctx, prompts)
+	}
+
+	generations, err := f.generate(ctx, prompts, prefill, options...)
+	if err != nil {
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleLLMError(ctx, err)
+		}
+		return generations, err
+	}
+
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleLLMEnd(ctx, llms.LLMResult{Generations: [][]*llms.Generation{generations}})
+	}
+	return generations, nil
+}
+
+func (f *LLM) generate(ctx context.Context, prompts []string, prefill string, options ...llms.CallOption) ([]*llms.Generation, error) {
+
+	opts := &llms.CallOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	err := llm.ValidateCallOptions(f.baseModelID, opts, opts.StreamingFunc != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = llm.CheckPromptSize(f.tokens, f.baseModelID, prompts[0]+prefill, opts.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.next(Call{Prompt: prompts[0], Prefill: prefill, Options: *opts})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := resp.Chunks
+	text := resp.Text
+	if len(chunks) == 0 {
+		chunks = []string{resp.Text}
+	}
+	if text == "" {
+		text = strings.Join(chunks, "")
+	}
+
+	if opts.StreamingFunc == nil {
+		if resp.Err != nil {
+			return nil, resp.Err
+		}
+		return []*llms.Generation{generation(prefill+text, resp)}, nil
+	}
+
+	handler := llm.CallbackStreamingFunc(f.CallbacksHandler, opts.StreamingFunc)
+
+	var streamed strings.Builder
+	streamed.WriteString(prefill)
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return []*llms.Generation{{Text: streamed.String()}}, err
+		}
+		if err := handler(ctx, []byte(chunk)); err != nil {
+			return []*llms.Generation{{Text: streamed.String()}}, llm.StreamAborted(err)
+		}
+		streamed.WriteString(chunk)
+	}
+
+	if resp.Err != nil {
+		return []*llms.Generation{{Text: streamed.String()}}, resp.Err
+	}
+
+	return []*llms.Generation{generation(streamed.String(), resp)}, nil
+}
+
+func generation(text string, resp Response) *llms.Generation {
+
+	g := &llms.Generation{Text: text, StopReason: resp.StopReason}
+	if resp.FunctionCall != nil {
+		g.Message = &schema.AIChatMessage{Content: text, FunctionCall: resp.FunctionCall}
+	}
+
+	return g
+}
+
+// next records the call and returns its response.
+func (f *LLM) next(call Call) (Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+
+	if len(f.responses) > 0 {
+		resp := f.responses[0]
+		f.responses = f.responses[1:]
+		return resp, nil
+	}
+
+	if f.respond != nil {
+		return f.respond(call.Prompt, call.Options), nil
+	}
+
+	return Response{}, ErrNoResponse
+}
+
+func (f *LLM) GeneratePrompt(ctx context.Context, prompts []schema.PromptValue, options ...llms.CallOption) (llms.LLMResult, error) {
+	return llms.GeneratePrompt(ctx, f, prompts, options...)
+}
+
+// ModelID returns the model set with llm.WithModel.
+func (f *LLM) ModelID() string {
+	return f.modelID
+}
+
+func (f *LLM) GetNumTokens(text string) int {
+	return f.tokens.CountTokens(text)
+}
@@ -0,0 +1,160 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestLLM(t *testing.T) {
+
+	ctx := context.Background()
+	failure := errors.New("throttled")
+
+	fake, err := NewLLM([]Response{
+		{Text: "Hello", StopReason: "stop_sequence"},
+		{Err: failure},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, llm.ModelClaudeV2, fake.ModelID())
+
+	g, err := fake.Generate(ctx, []string{"hi", "ignored"}, llms.WithMaxTokens(100))
+	assert.Nil(t, err)
+	assert.Len(t, g, 1)
+	assert.Equal(t, "Hello", g[0].Text)
+	assert.Equal(t, "stop_sequence", g[0].StopReason)
+
+	_, err = fake.Call(ctx, "again")
+	assert.ErrorIs(t, err, failure)
+
+	_, err = fake.Call(ctx, "once more")
+	assert.ErrorIs(t, err, ErrNoResponse)
+
+	fake.RespondWith(func(prompt string, _ llms.CallOptions) Response {
+		return Response{Text: "echo: " + prompt}
+	})
+
+	text, err := fake.Call(ctx, "ping")
+	assert.Nil(t, err)
+	assert.Equal(t, "echo: ping", text)
+
+	calls := fake.Calls()
+	assert.Len(t, calls, 4)
+	assert.Equal(t, "hi", calls[0].Prompt)
+	assert.Equal(t, 100, calls[0].Options.MaxTokens)
+
+	// options are checked against the model like claude.LLM does
+	_, err = fake.Call(ctx, "hi", llms.WithMaxTokens(1000000))
+	assert.ErrorIs(t, err, llm.ErrMaxTokensExceeded)
+	assert.Len(t, fake.Calls(), 4)
+
+	// the default estimator never rejects a prompt
+	_, err = fake.Call(ctx, strings.Repeat("word ", 200000))
+	assert.Nil(t, err)
+	assert.Len(t, fake.Calls(), 5)
+}
+
+// wordCounter counts whitespace-separated words.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestLLMPromptSize(t *testing.T) {
+
+	fake, err := NewLLM([]Response{{Text: "Hello"}}, llm.WithTokenCounter(wordCounter{}))
+	assert.Nil(t, err)
+
+	// the context window is checked like claude.LLM does with an exact counter
+	_, err = fake.Call(context.Background(), strings.Repeat("word ", 200000))
+	assert.ErrorIs(t, err, llm.ErrContextWindowExceeded)
+	assert.Len(t, fake.Calls(), 0)
+}
+
+func TestLLMStreaming(t *testing.T) {
+
+	ctx := context.Background()
+	failure := errors.New("connection reset")
+
+	fake, err := NewLLM([]Response{
+		{Chunks: []string{"Hel", "lo"}},
+		{Chunks: []string{"par", "tial"}, Err: failure},
+		{Chunks: []string{"a", "b", "c"}},
+	}, llm.WithModel(llm.ModelClaudeInstantV1))
+	assert.Nil(t, err)
+
+	var chunks []string
+	collect := llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+
+	text, err := fake.Call(ctx, "hi", collect)
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", text)
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+
+	text, err = fake.Call(ctx, "hi", collect)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, "partial", text)
+
+	stop := errors.New("enough")
+	text, err = fake.Call(ctx, "hi", llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		if string(chunk) == "b" {
+			return stop
+		}
+		return nil
+	}))
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, "a", text)
+}
+
+func TestEmbedder(t *testing.T) {
+
+	ctx := context.Background()
+
+	embedder, err := NewEmbedder(8)
+	assert.Nil(t, err)
+	assert.Equal(t, llm.ModelTitanEmbedText, embedder.ModelID())
+
+	docs, err := embedder.EmbedDocuments(ctx, []string{"cat", "dog"})
+	assert.Nil(t, err)
+	assert.Len(t, docs, 2)
+	assert.Len(t, docs[0], 8)
+	assert.NotEqual(t, docs[0], docs[1])
+
+	query, err := embedder.EmbedQuery(ctx, "cat")
+	assert.Nil(t, err)
+	assert.Equal(t, docs[0], query)
+
+	var norm float32
+	for _, x := range query {
+		norm += x * x
+	}
+	assert.InDelta(t, 1, norm, 1e-5)
+
+	failure := errors.New("throttled")
+	embedder.FailNext(failure)
+
+	_, err = embedder.EmbedQuery(ctx, "cat")
+	assert.ErrorIs(t, err, failure)
+
+	embedder.StripNewLines = true
+	stripped, err := embedder.EmbedQuery(ctx, "a\nb")
+	assert.Nil(t, err)
+	assert.Equal(t, Vector("a b", 8), stripped)
+
+	calls := embedder.Calls()
+	assert.Len(t, calls, 4)
+	assert.Equal(t, EmbedCall{Texts: []string{"cat", "dog"}}, calls[0])
+	assert.Equal(t, EmbedCall{Texts: []string{"a b"}, Query: true}, calls[3])
+
+	_, err = NewEmbedder(8, llm.WithModel(llm.ModelClaudeV2))
+	assert.NotNil(t, err)
+}
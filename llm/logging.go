@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// LevelPayload is the level at which request payloads are logged. It is below
+// slog.LevelDebug, so payloads are only logged by a handler explicitly
+// configured for it. Text values are redacted even then.
+const LevelPayload = slog.LevelDebug - 4
+
+// NopLogger returns a logger that discards everything. It is the default for
+// all the types in this module.
+func NopLogger() *slog.Logger {
+	return slog.New(nopHandler{})
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (nopHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h nopHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h nopHandler) WithGroup(string) slog.Handler           { return h }
+
+// Usage is the token usage reported by Bedrock for an invocation.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// InvocationMetrics is attached by Bedrock to the last chunk of a streamed
+// response, under the "amazon-bedrock-invocationMetrics" key.
+type InvocationMetrics struct {
+	InputTokenCount   int `json:"inputTokenCount"`
+	OutputTokenCount  int `json:"outputTokenCount"`
+	InvocationLatency int `json:"invocationLatency"`
+	FirstByteLatency  int `json:"firstByteLatency"`
+}
+
+func (m *InvocationMetrics) Usage() Usage {
+	if m == nil {
+		return Usage{}
+	}
+	return Usage{InputTokens: m.InputTokenCount, OutputTokens: m.OutputTokenCount}
+}
+
+const (
+	inputTokenCountHeader  = "X-Amzn-Bedrock-Input-Token-Count"
+	outputTokenCountHeader = "X-Amzn-Bedrock-Output-Token-Count"
+)
+
+// UsageFromMetadata extracts the token counts Bedrock returns as response
+// headers of InvokeModel.
+func UsageFromMetadata(metadata middleware.Metadata) Usage {
+	resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response)
+	if !ok || resp == nil {
+		return Usage{}
+	}
+
+	input, _ := strconv.Atoi(resp.Header.Get(inputTokenCountHeader))
+	output, _ := strconv.Atoi(resp.Header.Get(outputTokenCountHeader))
+
+	return Usage{InputTokens: input, OutputTokens: output}
+}
+
+// RequestID returns the AWS request ID of a successful invocation, if any.
+func RequestID(metadata middleware.Metadata) string {
+	id, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+	return id
+}
+
+// ErrorRequestID returns the AWS request ID of a failed invocation, if any.
+func ErrorRequestID(err error) string {
+	var re interface{ ServiceRequestID() string }
+	if errors.As(err, &re) {
+		return re.ServiceRequestID()
+	}
+	return ""
+}
+
+// LogPayload logs payload at LevelPayload with all text values redacted.
+func LogPayload(ctx context.Context, logger *slog.Logger, modelID string, payload []byte) {
+	if !logger.Enabled(ctx, LevelPayload) {
+		return
+	}
+
+	logger.LogAttrs(ctx, LevelPayload, "bedrock request payload",
+		slog.String("model_id", modelID),
+		slog.String("payload", RedactPayload(payload)))
+}
+
+// LogInvocation logs the outcome of a single Bedrock invocation. For failed
+// invocations the request ID is taken from err.
+func LogInvocation(ctx context.Context, logger *slog.Logger, modelID string, start time.Time, requestID string, usage Usage, err error) {
+
+	attrs := []slog.Attr{
+		slog.String("model_id", modelID),
+		slog.Duration("latency", time.Since(start)),
+	}
+
+	if requestID == "" {
+		requestID = ErrorRequestID(err)
+	}
+
+	if requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		logger.LogAttrs(ctx, slog.LevelError, "bedrock invocation failed", attrs...)
+		return
+	}
+
+	attrs = append(attrs,
+		slog.Int("input_tokens", usage.InputTokens),
+		slog.Int("output_tokens", usage.OutputTokens))
+
+	logger.LogAttrs(ctx, slog.LevelDebug, "bedrock invocation", attrs...)
+}
+
+// RedactPayload returns payload with every string value replaced by its
+// length, so that the shape of a request and its numeric parameters can be
+// inspected without exposing prompts.
+func RedactPayload(payload []byte) string {
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return fmt.Sprintf("[redacted: %d bytes]", len(payload))
+	}
+
+	redacted, err := json.Marshal(redact(v))
+	if err != nil {
+		return fmt.Sprintf("[redacted: %d bytes]", len(payload))
+	}
+
+	return string(redacted)
+}
+
+func redact(v any) any {
+	switch val := v.(type) {
+	case string:
+		return fmt.Sprintf("[redacted: %d chars]", len([]rune(val)))
+	case []any:
+		for i := range val {
+			val[i] = redact(val[i])
+		}
+		return val
+	case map[string]any:
+		for k := range val {
+			val[k] = redact(val[k])
+		}
+		return val
+	default:
+		return v
+	}
+}
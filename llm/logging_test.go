@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactPayload(t *testing.T) {
+
+	redacted := RedactPayload([]byte(`{"prompt":"my secret","max_tokens_to_sample":100,"stop_sequences":["\n\nHuman:"]}`))
+
+	assert.JSONEq(t, `{"prompt":"[redacted: 9 chars]","max_tokens_to_sample":100,"stop_sequences":["[redacted: 8 chars]"]}`, redacted)
+	assert.Equal(t, "[redacted: 3 bytes]", RedactPayload([]byte("foo")))
+}
+
+func TestLogPayloadIsOptIn(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	LogPayload(context.Background(), slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), "model", []byte(`{"prompt":"hi"}`))
+	assert.Empty(t, buf.String())
+
+	LogPayload(context.Background(), slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: LevelPayload})), "model", []byte(`{"prompt":"hi"}`))
+	assert.Contains(t, buf.String(), "model_id=model")
+	assert.NotContains(t, buf.String(), "hi\"")
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// MetricsRecorder receives measurements about Bedrock invocations. The metrics
+// package provides a Prometheus implementation.
+type MetricsRecorder interface {
+	RecordInvocation(ctx context.Context, stats InvocationStats)
+	RecordTimeToFirstToken(ctx context.Context, modelID string, d time.Duration)
+	RecordEmbeddedTexts(ctx context.Context, modelID string, n int)
+}
+
+// InvocationStats describes a single completed (or failed) Bedrock invocation.
+type InvocationStats struct {
+	ModelID   string
+	Operation string
+	Start     time.Time
+	Latency   time.Duration
+	RequestID string
+	Usage     Usage
+	// Retries is the number of attempts the SDK made beyond the first one.
+	Retries int
+	// Throttles is the number of attempts rejected with a throttling error,
+	// including the last one.
+	Throttles int
+	Err       error
+}
+
+// Attempts records the retried attempts of one invocation. The SDK reports
+// them only in the metadata of successful invocations, so pass the option of
+// an Attempts to the invocation and the Attempts to NewInvocationStats to
+// count the retries and throttles of failed invocations too. The zero value
+// is ready to use.
+type Attempts struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// ClientOption returns the client option that records retried attempts.
+func (a *Attempts) ClientOption() func(*bedrockruntime.Options) {
+	return func(o *bedrockruntime.Options) {
+		if o.Retryer == nil {
+			return
+		}
+		o.Retryer = &notifyingRetryer{RetryerV2: asRetryerV2(o.Retryer), notify: func(_ int, err error) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.errs = append(a.errs, err)
+		}}
+	}
+}
+
+// NewInvocationStats fills in the details available from the metadata of a
+// successful invocation and from attempts. metadata may be nil for failed
+// invocations and attempts may be nil.
+func NewInvocationStats(modelID, operation string, start time.Time, metadata *middleware.Metadata, attempts *Attempts, usage Usage, err error) InvocationStats {
+
+	stats := InvocationStats{
+		ModelID:   modelID,
+		Operation: operation,
+		Start:     start,
+		Latency:   time.Since(start),
+		Usage:     usage,
+		Err:       err,
+	}
+
+	if metadata != nil {
+		stats.RequestID = RequestID(*metadata)
+		if results, ok := retry.GetAttemptResults(*metadata); ok && len(results.Results) > 1 {
+			stats.Retries = len(results.Results) - 1
+		}
+	}
+
+	if attempts != nil {
+		attempts.mu.Lock()
+		defer attempts.mu.Unlock()
+
+		stats.Retries = len(attempts.errs)
+		for _, err := range attempts.errs {
+			if IsThrottling(err) {
+				stats.Throttles++
+			}
+		}
+	}
+
+	if IsThrottling(err) {
+		stats.Throttles++
+	}
+
+	return stats
+}
+
+// ObserveInvocation logs stats and, if metrics is not nil, records them.
+func ObserveInvocation(ctx context.Context, logger *slog.Logger, metrics MetricsRecorder, stats InvocationStats) {
+
+	LogInvocation(ctx, logger, stats.ModelID, stats.Start, stats.RequestID, stats.Usage, stats.Err)
+
+	if metrics != nil {
+		metrics.RecordInvocation(ctx, stats)
+	}
+}
+
+// MeasureStreamingFunc wraps handler so that the time between now and the
+// first chunk is recorded as the time to first token.
+func MeasureStreamingFunc(metrics MetricsRecorder, modelID string, handler func(ctx context.Context, chunk []byte) error) func(ctx context.Context, chunk []byte) error {
+	if metrics == nil {
+		return handler
+	}
+
+	start := time.Now()
+	first := true
+
+	return func(ctx context.Context, chunk []byte) error {
+		if first {
+			first = false
+			metrics.RecordTimeToFirstToken(ctx, modelID, time.Since(start))
+		}
+		return handler(ctx, chunk)
+	}
+}
+
+// IsThrottling reports whether err is a Bedrock throttling error.
+func IsThrottling(err error) bool {
+	var te *types.ThrottlingException
+	return errors.As(err, &te)
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvocationStatsThrottledThenFailed(t *testing.T) {
+
+	// two throttled attempts, then a validation error that is not retried
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("X-Amzn-ErrorType", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			w.Header().Set("X-Amzn-ErrorType", "ValidationException")
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(`{"message":"stand-in error"}`))
+	}))
+	defer server.Close()
+
+	client := bedrockruntime.New(bedrockruntime.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	})
+
+	var attempts Attempts
+	start := time.Now()
+
+	_, err := client.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{ModelId: aws.String(ModelClaudeV2), Body: []byte(`{}`)}, attempts.ClientOption())
+	assert.Error(t, err)
+	assert.False(t, IsThrottling(err))
+	assert.Equal(t, int32(3), calls.Load())
+
+	stats := NewInvocationStats(ModelClaudeV2, OperationTextCompletion, start, nil, &attempts, Usage{}, err)
+	assert.Equal(t, 2, stats.Retries)
+	assert.Equal(t, 2, stats.Throttles)
+
+	// without an Attempts only the last attempt is known
+	stats = NewInvocationStats(ModelClaudeV2, OperationTextCompletion, start, nil, nil, Usage{}, err)
+	assert.Equal(t, 0, stats.Retries)
+	assert.Equal(t, 0, stats.Throttles)
+}
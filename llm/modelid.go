@@ -0,0 +1,68 @@
+package llm
+
+import "strings"
+
+// inferenceProfileGeoPrefixes are the geographies of cross-region inference
+// profile IDs such as "us.anthropic.claude-v2".
+var inferenceProfileGeoPrefixes = map[string]bool{
+	"us":     true,
+	"us-gov": true,
+	"eu":     true,
+	"apac":   true,
+	"ca":     true,
+	"jp":     true,
+	"au":     true,
+	"global": true,
+}
+
+// BaseModelID returns the ID of the foundation model behind modelID, which may
+// be:
+//
+//   - an on-demand model ID ("anthropic.claude-v2"), returned as is
+//   - a cross-region inference profile ID ("us.anthropic.claude-v2")
+//   - a foundation model, inference profile or custom model ARN
+//
+// It returns "" for provisioned throughput and application inference profile
+// ARNs, which do not name their model: declare it with WithBaseModel.
+func BaseModelID(modelID string) string {
+
+	if strings.HasPrefix(modelID, "arn:") {
+		// arn:partition:bedrock:region:account:resource-type/resource
+		parts := strings.SplitN(modelID, ":", 6)
+		if len(parts) < 6 {
+			return ""
+		}
+
+		resourceType, resource, _ := strings.Cut(parts[5], "/")
+
+		switch resourceType {
+		case "foundation-model":
+			return resource
+		case "inference-profile":
+			return BaseModelID(resource)
+		case "custom-model":
+			// custom-model/<base model ID>/<custom model ID>
+			if base, _, ok := strings.Cut(resource, "/"); ok {
+				return base
+			}
+			return ""
+		default:
+			return ""
+		}
+	}
+
+	if geo, rest, ok := strings.Cut(modelID, "."); ok && inferenceProfileGeoPrefixes[geo] {
+		return rest
+	}
+
+	return modelID
+}
+
+// ResolveBaseModel returns the base model declared in opts with WithBaseModel
+// or, if there is none, the one derived from modelID by BaseModelID.
+func ResolveBaseModel(modelID string, opts *ConfigOptions) string {
+	if opts.BaseModelID != "" {
+		return opts.BaseModelID
+	}
+	return BaseModelID(modelID)
+}
@@ -0,0 +1,219 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Bedrock model IDs, see
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+const (
+	ModelClaudeV2           = "anthropic.claude-v2"
+	ModelClaudeV21          = "anthropic.claude-v2:1"
+	ModelClaudeInstantV1    = "anthropic.claude-instant-v1"
+	ModelLlama2Chat13B      = "meta.llama2-13b-chat-v1"
+	ModelLlama2Chat70B      = "meta.llama2-70b-chat-v1"
+	ModelCohereCommand      = "cohere.command-text-v14"
+	ModelCohereCommandLight = "cohere.command-light-text-v14"
+	ModelTitanTextExpress   = "amazon.titan-text-express-v1"
+	ModelTitanTextLite      = "amazon.titan-text-lite-v1"
+	ModelTitanEmbedText     = "amazon.titan-embed-text-v1"
+)
+
+type Provider string
+
+const (
+	ProviderAnthropic Provider = "anthropic"
+	ProviderMeta      Provider = "meta"
+	ProviderCohere    Provider = "cohere"
+	ProviderAmazon    Provider = "amazon"
+)
+
+type Modality string
+
+const (
+	ModalityText      Modality = "text"
+	ModalityImage     Modality = "image"
+	ModalityEmbedding Modality = "embedding"
+)
+
+// Parameter is an inference parameter, named after the llms.CallOptions it
+// comes from.
+type Parameter string
+
+const (
+	ParameterMaxTokens     Parameter = "max_tokens"
+	ParameterTemperature   Parameter = "temperature"
+	ParameterTopP          Parameter = "top_p"
+	ParameterTopK          Parameter = "top_k"
+	ParameterStopSequences Parameter = "stop_sequences"
+)
+
+var (
+	ErrUnsupportedModel     = errors.New("model not supported")
+	ErrUnsupportedParameter = errors.New("parameter not supported by model")
+	ErrStreamingUnsupported = errors.New("streaming not supported by model")
+	ErrMaxTokensExceeded    = errors.New("max tokens exceeds the model limit")
+)
+
+// Pricing is the on-demand price of a model in USD per 1000 tokens.
+type Pricing struct {
+	InputPer1K  float64
+	OutputPer1K float64
+}
+
+// ModelInfo describes the capabilities of a Bedrock model.
+type ModelInfo struct {
+	ID       string
+	Provider Provider
+	// ContextWindow is the maximum number of input plus output tokens.
+	ContextWindow int
+	// MaxOutputTokens is zero for models that do not generate text.
+	MaxOutputTokens  int
+	Parameters       []Parameter
+	Streaming        bool
+	InputModalities  []Modality
+	OutputModalities []Modality
+	Tools            bool
+	Pricing          Pricing
+}
+
+// Supports reports whether the model accepts p.
+func (m ModelInfo) Supports(p Parameter) bool {
+	for _, q := range m.Parameters {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Outputs reports whether the model produces modality.
+func (m ModelInfo) Outputs(modality Modality) bool {
+	for _, o := range m.OutputModalities {
+		if o == modality {
+			return true
+		}
+	}
+	return false
+}
+
+// Cost returns the on-demand price in USD of an invocation with usage.
+func (m ModelInfo) Cost(usage Usage) float64 {
+	return float64(usage.InputTokens)/1000*m.Pricing.InputPer1K + float64(usage.OutputTokens)/1000*m.Pricing.OutputPer1K
+}
+
+var (
+	textParameters = []Parameter{ParameterMaxTokens, ParameterTemperature, ParameterTopP, ParameterTopK, ParameterStopSequences}
+	textOnly       = []Modality{ModalityText}
+	embeddingOnly  = []Modality{ModalityEmbedding}
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]ModelInfo{}
+)
+
+func init() {
+	for _, m := range []ModelInfo{
+		{ID: ModelClaudeV2, Provider: ProviderAnthropic, ContextWindow: 100000, MaxOutputTokens: 4096, Parameters: textParameters, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.008, 0.024}},
+		{ID: ModelClaudeV21, Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 4096, Parameters: textParameters, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.008, 0.024}},
+		{ID: ModelClaudeInstantV1, Provider: ProviderAnthropic, ContextWindow: 100000, MaxOutputTokens: 4096, Parameters: textParameters, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.0008, 0.0024}},
+		{ID: ModelLlama2Chat13B, Provider: ProviderMeta, ContextWindow: 4096, MaxOutputTokens: 2048, Parameters: []Parameter{ParameterMaxTokens, ParameterTemperature, ParameterTopP}, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.00075, 0.001}},
+		{ID: ModelLlama2Chat70B, Provider: ProviderMeta, ContextWindow: 4096, MaxOutputTokens: 2048, Parameters: []Parameter{ParameterMaxTokens, ParameterTemperature, ParameterTopP}, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.00195, 0.00256}},
+		{ID: ModelCohereCommand, Provider: ProviderCohere, ContextWindow: 4096, MaxOutputTokens: 4096, Parameters: textParameters, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.0015, 0.002}},
+		{ID: ModelCohereCommandLight, Provider: ProviderCohere, ContextWindow: 4096, MaxOutputTokens: 4096, Parameters: textParameters, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.0003, 0.0006}},
+		{ID: ModelTitanTextExpress, Provider: ProviderAmazon, ContextWindow: 8192, MaxOutputTokens: 8192, Parameters: []Parameter{ParameterMaxTokens, ParameterTemperature, ParameterTopP, ParameterStopSequences}, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.0008, 0.0016}},
+		{ID: ModelTitanTextLite, Provider: ProviderAmazon, ContextWindow: 4096, MaxOutputTokens: 4096, Parameters: []Parameter{ParameterMaxTokens, ParameterTemperature, ParameterTopP, ParameterStopSequences}, Streaming: true, InputModalities: textOnly, OutputModalities: textOnly, Pricing: Pricing{0.0003, 0.0004}},
+		{ID: ModelTitanEmbedText, Provider: ProviderAmazon, ContextWindow: 8192, InputModalities: textOnly, OutputModalities: embeddingOnly, Pricing: Pricing{InputPer1K: 0.0001}},
+	} {
+		RegisterModel(m)
+	}
+}
+
+// RegisterModel adds m to the registry, replacing any model with the same ID.
+// Use it to describe models this module does not know about yet.
+func RegisterModel(m ModelInfo) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[m.ID] = m
+}
+
+// LookupModel returns the description of modelID.
+func LookupModel(modelID string) (ModelInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	m, ok := registry[modelID]
+	return m, ok
+}
+
+// Models returns all registered models, sorted by ID.
+func Models() []ModelInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	models := make([]ModelInfo, 0, len(registry))
+	for _, m := range registry {
+		models = append(models, m)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// ProviderOf returns the provider of modelID, based on the prefix of its base
+// model (see BaseModelID).
+func ProviderOf(modelID string) Provider {
+	provider, _, _ := strings.Cut(BaseModelID(modelID), ".")
+	return Provider(provider)
+}
+
+// CheckModel returns an error wrapping ErrUnsupportedModel if modelID is a
+// registered model that is not from provider or does not produce output. The
+// model packages use it because each of them implements the request format of
+// a single provider. Unknown models are not checked.
+func CheckModel(modelID string, provider Provider, output Modality) error {
+
+	m, ok := LookupModel(modelID)
+	if !ok {
+		return nil
+	}
+
+	if m.Provider != provider || !m.Outputs(output) {
+		return fmt.Errorf("%w: %s is not a %s %s model", ErrUnsupportedModel, modelID, provider, output)
+	}
+
+	return nil
+}
+
+// ValidateCallOptions checks opts against the capabilities of modelID.
+// Unknown models are not checked.
+func ValidateCallOptions(modelID string, opts *llms.CallOptions, streaming bool) error {
+
+	m, ok := LookupModel(modelID)
+	if !ok {
+		return nil
+	}
+
+	if streaming && !m.Streaming {
+		return fmt.Errorf("%w: %s", ErrStreamingUnsupported, modelID)
+	}
+
+	if m.MaxOutputTokens > 0 && opts.MaxTokens > m.MaxOutputTokens {
+		return fmt.Errorf("%w: %d requested, %s allows %d", ErrMaxTokensExceeded, opts.MaxTokens, modelID, m.MaxOutputTokens)
+	}
+
+	if opts.TopK > 0 && !m.Supports(ParameterTopK) {
+		return fmt.Errorf("%w: %s does not accept %s", ErrUnsupportedParameter, modelID, ParameterTopK)
+	}
+
+	if len(opts.StopWords) > 0 && !m.Supports(ParameterStopSequences) {
+		return fmt.Errorf("%w: %s does not accept %s", ErrUnsupportedParameter, modelID, ParameterStopSequences)
+	}
+
+	return nil
+}
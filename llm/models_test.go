@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestLookupModel(t *testing.T) {
+
+	m, ok := LookupModel(ModelClaudeV2)
+	assert.True(t, ok)
+	assert.Equal(t, ProviderAnthropic, m.Provider)
+	assert.True(t, m.Streaming)
+	assert.True(t, m.Supports(ParameterTopK))
+	assert.InDelta(t, 0.032, m.Cost(Usage{InputTokens: 1000, OutputTokens: 1000}), 1e-9)
+
+	_, ok = LookupModel("anthropic.foobar")
+	assert.False(t, ok)
+
+	RegisterModel(ModelInfo{ID: "anthropic.foobar", Provider: ProviderAnthropic, ContextWindow: 10})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "anthropic.foobar")
+		registryMu.Unlock()
+	}()
+
+	window, ok := ContextWindow("anthropic.foobar")
+	assert.True(t, ok)
+	assert.Equal(t, 10, window)
+}
+
+func TestCheckModel(t *testing.T) {
+
+	assert.NoError(t, CheckModel(ModelLlama2Chat70B, ProviderMeta, ModalityText))
+	assert.NoError(t, CheckModel("llama.foobar", ProviderMeta, ModalityText))
+	assert.ErrorIs(t, CheckModel(ModelClaudeV2, ProviderMeta, ModalityText), ErrUnsupportedModel)
+	assert.ErrorIs(t, CheckModel(ModelTitanTextLite, ProviderAmazon, ModalityEmbedding), ErrUnsupportedModel)
+}
+
+func TestValidateCallOptions(t *testing.T) {
+
+	assert.NoError(t, ValidateCallOptions(ModelClaudeV2, &llms.CallOptions{MaxTokens: 100, TopK: 5, StopWords: []string{"\n"}}, true))
+	assert.ErrorIs(t, ValidateCallOptions(ModelLlama2Chat13B, &llms.CallOptions{TopK: 5}, false), ErrUnsupportedParameter)
+	assert.ErrorIs(t, ValidateCallOptions(ModelLlama2Chat13B, &llms.CallOptions{StopWords: []string{"\n"}}, false), ErrUnsupportedParameter)
+	assert.ErrorIs(t, ValidateCallOptions(ModelLlama2Chat13B, &llms.CallOptions{MaxTokens: 4096}, false), ErrMaxTokensExceeded)
+	assert.ErrorIs(t, ValidateCallOptions(ModelTitanEmbedText, &llms.CallOptions{}, true), ErrStreamingUnsupported)
+	assert.NoError(t, ValidateCallOptions("llama.foobar", &llms.CallOptions{TopK: 5}, true))
+}
+
+func TestBaseModelID(t *testing.T) {
+
+	for modelID, base := range map[string]string{
+		"anthropic.claude-v2":        "anthropic.claude-v2",
+		"us.anthropic.claude-v2":     "anthropic.claude-v2",
+		"eu.meta.llama2-13b-chat-v1": "meta.llama2-13b-chat-v1",
+		"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-v2:1":                         "anthropic.claude-v2:1",
+		"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-v2":           "anthropic.claude-v2",
+		"arn:aws:bedrock:us-east-1:123456789012:custom-model/amazon.titan-text-express-v1:0:8k/abc": "amazon.titan-text-express-v1:0:8k",
+		"arn:aws:bedrock:us-east-1:123456789012:provisioned-model/abc":                              "",
+		"arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc":                  "",
+	} {
+		assert.Equal(t, base, BaseModelID(modelID), modelID)
+	}
+
+	assert.Equal(t, ProviderAnthropic, ProviderOf("us.anthropic.claude-v2"))
+	assert.Equal(t, ModelCohereCommand, ResolveBaseModel("arn:aws:bedrock:us-east-1:123456789012:provisioned-model/abc", &ConfigOptions{BaseModelID: ModelCohereCommand}))
+}
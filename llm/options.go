@@ -1,13 +1,30 @@
 package llm
 
-import "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+import (
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type ConfigOption func(*ConfigOptions)
 
 type ConfigOptions struct {
 	DontUseHumanAssistantPrompt bool
 	BedrockRuntimeClient        *bedrockruntime.Client
+	RuntimeClient               RuntimeClient
+	FailoverRegions             []string
+	CircuitBreaker              bool
+	CircuitBreakerOptions       []CircuitBreakerOption
+	Hedging                     bool
+	HedgeOptions                []HedgeOption
 	ModelID                     string
+	BaseModelID                 string
+	Logger                      *slog.Logger
+	TracerProvider              trace.TracerProvider
+	Metrics                     MetricsRecorder
+	TokenCounter                TokenCounter
+	RateLimiter                 *RateLimiter
 }
 
 func DontUseHumanAssistantPrompt() ConfigOption {
@@ -22,8 +39,97 @@ func WithBedrockRuntimeClient(client *bedrockruntime.Client) ConfigOption {
 	}
 }
 
+// WithRuntimeClient sets the client used for invocations, for example a
+// FailoverClient or a test double. It takes precedence over
+// WithBedrockRuntimeClient.
+func WithRuntimeClient(client RuntimeClient) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.RuntimeClient = client
+	}
+}
+
+// WithFailoverRegions adds regions to fail over to, in order, when the region
+// passed to New is throttled or unavailable (see FailoverClient). It has no
+// effect together with WithRuntimeClient or WithBedrockRuntimeClient.
+func WithFailoverRegions(regions ...string) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.FailoverRegions = regions
+	}
+}
+
+// WithCircuitBreaker wraps the client of every region in a CircuitBreaker
+// configured with options. State changes are logged and recorded with the
+// logger and metrics options.
+func WithCircuitBreaker(options ...CircuitBreakerOption) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.CircuitBreaker = true
+		o.CircuitBreakerOptions = options
+	}
+}
+
+// WithHedging sends a duplicate invocation when a model is slow to respond
+// and uses the first response (see HedgingClient).
+func WithHedging(options ...HedgeOption) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Hedging = true
+		o.HedgeOptions = options
+	}
+}
+
 func WithModel(modelID string) ConfigOption {
 	return func(o *ConfigOptions) {
 		o.ModelID = modelID
 	}
 }
+
+// WithBaseModel declares the foundation model behind the model set with
+// WithModel, for identifiers that do not name it, such as provisioned
+// throughput ARNs. The base model determines the request format, limits and
+// token counting.
+func WithBaseModel(modelID string) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.BaseModelID = modelID
+	}
+}
+
+// WithLogger sets the logger used for invocation logs. Nothing is logged by
+// default.
+func WithLogger(logger *slog.Logger) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing: every Bedrock invocation is
+// recorded as a span created by a tracer from tp.
+func WithTracerProvider(tp trace.TracerProvider) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.TracerProvider = tp
+	}
+}
+
+// WithMetrics records invocation metrics with m (see the metrics package for
+// a Prometheus implementation).
+func WithMetrics(m MetricsRecorder) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Metrics = m
+	}
+}
+
+// WithTokenCounter replaces the estimator used for GetNumTokens and for the
+// oversize strategies of TitanEmbedder, for example with an exact tokenizer.
+// Prompts are only checked against the context window before invoking with
+// such a counter; see CheckPromptSize.
+func WithTokenCounter(counter TokenCounter) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.TokenCounter = counter
+	}
+}
+
+// WithRateLimiter keeps invocations within the budgets of limiter. Pass the
+// same limiter to several LLM and embedder instances to share the budgets.
+func WithRateLimiter(limiter *RateLimiter) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.RateLimiter = limiter
+	}
+}
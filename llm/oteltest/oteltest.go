@@ -0,0 +1,16 @@
+// Package oteltest provides an in-memory OpenTelemetry setup for asserting on
+// the spans produced by the types in this module.
+package oteltest
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// NewTracerProvider returns a tracer provider that synchronously exports every
+// ended span to the returned in-memory exporter. Pass it to
+// llm.WithTracerProvider.
+func NewTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
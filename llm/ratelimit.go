@@ -0,0 +1,276 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned (wrapped) by a RateLimiter that rejects
+// invocations, or when an invocation needs more tokens than the limit allows
+// in a minute.
+var ErrRateLimited = errors.New("client-side rate limit exceeded")
+
+// RateLimit is a per-minute budget for one model, like the Bedrock quotas.
+// Zero means no limit.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimiter keeps invocations within per-model request and token budgets.
+// Attach one limiter to several LLM and embedder instances with
+// WithRateLimiter so that they share the budget.
+//
+// Before each invocation the limiter takes one request and the estimated
+// tokens (prompt plus max tokens) from the budget of the model. Once the
+// response reports the actual usage, the difference is given back or taken.
+// Failed invocations keep their reservation.
+// Budgets refill continuously. When a budget is exhausted the limiter waits
+// until it has refilled, or fails with ErrRateLimited if created with
+// WithRejectWhenLimited.
+type RateLimiter struct {
+	limits       map[string]RateLimit
+	defaultLimit RateLimit
+	reject       bool
+	now          func() time.Time
+	sleep        func(context.Context, time.Duration) error
+
+	mu      sync.Mutex
+	budgets map[string]*budget
+}
+
+// budget holds the remaining requests and tokens of a model. Tokens can be
+// negative after a response used more tokens than estimated.
+type budget struct {
+	limit    RateLimit
+	requests float64
+	tokens   float64
+	updated  time.Time
+}
+
+type RateLimiterOption func(*RateLimiter)
+
+// WithModelRateLimit sets the limit of modelID.
+func WithModelRateLimit(modelID string, limit RateLimit) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.limits[modelID] = limit
+	}
+}
+
+// WithDefaultRateLimit sets the limit of models without their own limit. By
+// default they are not limited.
+func WithDefaultRateLimit(limit RateLimit) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.defaultLimit = limit
+	}
+}
+
+// WithRejectWhenLimited makes the limiter fail with ErrRateLimited instead of
+// waiting when a budget is exhausted.
+func WithRejectWhenLimited() RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.reject = true
+	}
+}
+
+func NewRateLimiter(options ...RateLimiterOption) *RateLimiter {
+
+	l := &RateLimiter{
+		limits:  map[string]RateLimit{},
+		now:     time.Now,
+		sleep:   sleep,
+		budgets: map[string]*budget{},
+	}
+
+	for _, opt := range options {
+		opt(l)
+	}
+
+	return l
+}
+
+// Reservation is the part of a budget taken for one invocation.
+type Reservation struct {
+	limiter *RateLimiter
+	modelID string
+	tokens  int
+	settled bool
+}
+
+// Reserve takes one request and tokens from the budget of modelID, waiting
+// for the budget to refill if needed. A nil limiter reserves nothing.
+func (l *RateLimiter) Reserve(ctx context.Context, modelID string, tokens int) (*Reservation, error) {
+
+	if l == nil {
+		return nil, nil
+	}
+
+	for {
+		wait, err := l.take(modelID, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if wait == 0 {
+			return &Reservation{limiter: l, modelID: modelID, tokens: tokens}, nil
+		}
+
+		if l.reject {
+			return nil, fmt.Errorf("%w: %s, retry in %s", ErrRateLimited, modelID, wait.Round(time.Millisecond))
+		}
+
+		if err := l.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Settle reconciles the reservation with the tokens the invocation actually
+// used, as reported in the response. A zero usage, as for failed invocations
+// and responses without usage, keeps the whole reservation, because Bedrock
+// may have counted tokens the response does not report. Settle does nothing
+// on a nil reservation or after the first call.
+func (r *Reservation) Settle(usage Usage) {
+
+	if r == nil || r.settled {
+		return
+	}
+	r.settled = true
+
+	if usage.InputTokens+usage.OutputTokens == 0 {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+
+	b := r.limiter.budgets[r.modelID]
+	if b == nil || b.limit.TokensPerMinute == 0 {
+		return
+	}
+
+	b.refill(r.limiter.now())
+	b.tokens += float64(r.tokens - (usage.InputTokens + usage.OutputTokens))
+	b.tokens = math.Min(b.tokens, float64(b.limit.TokensPerMinute))
+}
+
+type reservationKey struct{}
+
+// ContextWithReservation returns ctx carrying r, so that clients sending more
+// than one invocation for it, such as HedgingClient, can reserve for the
+// others. The LLM types in this module use it for every invocation.
+func ContextWithReservation(ctx context.Context, r *Reservation) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, reservationKey{}, r)
+}
+
+// reserveDuplicate takes one request and the tokens of the reservation in ctx
+// for a duplicate invocation, without waiting. It returns false if the budget
+// cannot cover the duplicate, and true if ctx has no reservation. The
+// duplicate is never settled: whether Bedrock counts a cancelled invocation
+// is not reported, so its estimate is kept.
+func reserveDuplicate(ctx context.Context) bool {
+
+	r, ok := ctx.Value(reservationKey{}).(*Reservation)
+	if !ok {
+		return true
+	}
+
+	wait, err := r.limiter.take(r.modelID, r.tokens)
+	return err == nil && wait == 0
+}
+
+// take takes one request and tokens from the budget of modelID. If the
+// budget is too low it takes nothing and returns how long to wait.
+func (l *RateLimiter) take(modelID string, tokens int) (time.Duration, error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.budget(modelID)
+	if b == nil {
+		return 0, nil
+	}
+
+	if b.limit.TokensPerMinute > 0 && tokens > b.limit.TokensPerMinute {
+		return 0, fmt.Errorf("%w: %s, %d tokens exceed the limit of %d per minute", ErrRateLimited, modelID, tokens, b.limit.TokensPerMinute)
+	}
+
+	b.refill(l.now())
+
+	var wait time.Duration
+	if b.limit.RequestsPerMinute > 0 && b.requests < 1 {
+		wait = refillTime(1-b.requests, b.limit.RequestsPerMinute)
+	}
+	if b.limit.TokensPerMinute > 0 && b.tokens < float64(tokens) {
+		wait = max(wait, refillTime(float64(tokens)-b.tokens, b.limit.TokensPerMinute))
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	b.requests--
+	b.tokens -= float64(tokens)
+
+	return 0, nil
+}
+
+// budget returns the budget of modelID, or nil if the model is not limited.
+func (l *RateLimiter) budget(modelID string) *budget {
+
+	if b, ok := l.budgets[modelID]; ok {
+		return b
+	}
+
+	limit, ok := l.limits[modelID]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	if limit == (RateLimit{}) {
+		return nil
+	}
+
+	b := &budget{
+		limit:    limit,
+		requests: float64(limit.RequestsPerMinute),
+		tokens:   float64(limit.TokensPerMinute),
+		updated:  l.now(),
+	}
+	l.budgets[modelID] = b
+
+	return b
+}
+
+func (b *budget) refill(now time.Time) {
+
+	minutes := now.Sub(b.updated).Minutes()
+	if minutes <= 0 {
+		return
+	}
+	b.updated = now
+
+	b.requests = math.Min(b.requests+minutes*float64(b.limit.RequestsPerMinute), float64(b.limit.RequestsPerMinute))
+	b.tokens = math.Min(b.tokens+minutes*float64(b.limit.TokensPerMinute), float64(b.limit.TokensPerMinute))
+}
+
+// refillTime returns how long a budget of perMinute takes to refill by n.
+func refillTime(n float64, perMinute int) time.Duration {
+	return time.Duration(math.Ceil(n / float64(perMinute) * float64(time.Minute)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock makes a RateLimiter sleep by moving time forward.
+func fakeClock(l *RateLimiter) *[]time.Duration {
+	now := time.Now()
+	var waits []time.Duration
+
+	l.now = func() time.Time { return now }
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	return &waits
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+
+	l := NewRateLimiter(WithModelRateLimit(ModelClaudeV2, RateLimit{RequestsPerMinute: 2}))
+	waits := fakeClock(l)
+
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Reserve(ctx, ModelClaudeV2, 100)
+		assert.NoError(t, err)
+	}
+
+	// the third request waits for one request to refill
+	assert.Equal(t, []time.Duration{30 * time.Second}, *waits)
+
+	// other models are not limited
+	_, err := l.Reserve(ctx, ModelClaudeV21, 100)
+	assert.NoError(t, err)
+	assert.Len(t, *waits, 1)
+}
+
+func TestRateLimiterTokens(t *testing.T) {
+
+	l := NewRateLimiter(WithDefaultRateLimit(RateLimit{TokensPerMinute: 1000}))
+	waits := fakeClock(l)
+
+	ctx := context.Background()
+
+	r, err := l.Reserve(ctx, ModelClaudeV2, 800)
+	assert.NoError(t, err)
+
+	// the response used fewer tokens than estimated: the rest is given back
+	r.Settle(Usage{InputTokens: 100, OutputTokens: 200})
+	r.Settle(Usage{})
+
+	r, err = l.Reserve(ctx, ModelClaudeV2, 700)
+	assert.NoError(t, err)
+	assert.Empty(t, *waits)
+
+	// the response used more tokens than estimated: the budget goes into debt
+	r.Settle(Usage{InputTokens: 600, OutputTokens: 400})
+
+	_, err = l.Reserve(ctx, ModelClaudeV2, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{36 * time.Second}, *waits)
+
+	_, err = l.Reserve(ctx, ModelClaudeV2, 2000)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestRateLimiterReject(t *testing.T) {
+
+	l := NewRateLimiter(WithModelRateLimit(ModelClaudeV2, RateLimit{RequestsPerMinute: 1, TokensPerMinute: 1000}), WithRejectWhenLimited())
+	waits := fakeClock(l)
+
+	ctx := context.Background()
+
+	r, err := l.Reserve(ctx, ModelClaudeV2, 500)
+	assert.NoError(t, err)
+	r.Settle(Usage{InputTokens: 500})
+
+	_, err = l.Reserve(ctx, ModelClaudeV2, 500)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Empty(t, *waits)
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+
+	l := NewRateLimiter(WithModelRateLimit(ModelClaudeV2, RateLimit{RequestsPerMinute: 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := l.Reserve(ctx, ModelClaudeV2, 0)
+	assert.NoError(t, err)
+
+	cancel()
+
+	_, err = l.Reserve(ctx, ModelClaudeV2, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNilRateLimiter(t *testing.T) {
+
+	var l *RateLimiter
+
+	r, err := l.Reserve(context.Background(), ModelClaudeV2, 100)
+	assert.NoError(t, err)
+	r.Settle(Usage{InputTokens: 100})
+}
+
+func TestRateLimiterKeepsReservationWithoutUsage(t *testing.T) {
+
+	l := NewRateLimiter(WithDefaultRateLimit(RateLimit{TokensPerMinute: 1000}), WithRejectWhenLimited())
+	fakeClock(l)
+
+	ctx := context.Background()
+
+	r, err := l.Reserve(ctx, ModelClaudeV2, 800)
+	assert.NoError(t, err)
+	r.Settle(Usage{})
+
+	_, err = l.Reserve(ctx, ModelClaudeV2, 800)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
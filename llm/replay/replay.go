@@ -0,0 +1,414 @@
+// Package replay records Bedrock invocations to fixture files and serves them
+// back, so that tests can run against real model outputs without calling
+// Bedrock. It works at the HTTP level, below the AWS SDK, so it applies to
+// every type in this module that takes llm.WithBedrockRuntimeClient.
+//
+// Record fixtures once against Bedrock:
+//
+//	recorder, err := replay.NewRecorder("testdata/bedrock", nil)
+//	client, err := recorder.NewClient(ctx, "us-east-1")
+//	claudeLLM, err := claude.New("us-east-1", llm.WithBedrockRuntimeClient(client))
+//
+// and replay them in CI with replay.NewReplayer("testdata/bedrock") instead.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream/eventstreamapi"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// ErrNoFixture is returned (wrapped) by a replayer for a request that was not
+// recorded.
+var ErrNoFixture = errors.New("no recorded fixture for request")
+
+const (
+	OperationInvokeModel                   = "InvokeModel"
+	OperationInvokeModelWithResponseStream = "InvokeModelWithResponseStream"
+)
+
+type Mode int
+
+const (
+	ModeReplay Mode = iota
+	ModeRecord
+)
+
+// Fixture is a recorded request and response.
+type Fixture struct {
+	ModelID   string `json:"model_id"`
+	Operation string `json:"operation"`
+	Request   string `json:"request"`
+
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the response body of InvokeModel and of failed invocations.
+	Body string `json:"body,omitempty"`
+	// Events are the messages of a response stream, in order.
+	Events []Event `json:"events,omitempty"`
+}
+
+// Event is a message of a response stream.
+type Event struct {
+	// Headers are the event stream headers, such as :event-type.
+	Headers map[string]string `json:"headers"`
+	// Chunk is the decoded model output of chunk events.
+	Chunk string `json:"chunk,omitempty"`
+	// Payload is the payload of other messages, such as exceptions.
+	Payload string `json:"payload,omitempty"`
+}
+
+// Transport is a bedrockruntime.HTTPClient that records responses to, or
+// replays them from, a directory of fixture files. Requests are matched by
+// model ID, operation and request body.
+type Transport struct {
+	mode   Mode
+	dir    string
+	client bedrockruntime.HTTPClient
+}
+
+var _ bedrockruntime.HTTPClient = (*Transport)(nil)
+
+// NewRecorder returns a Transport that sends requests with client
+// (http.DefaultClient if nil) and writes every response to a fixture in dir.
+func NewRecorder(dir string, client bedrockruntime.HTTPClient) (*Transport, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Transport{mode: ModeRecord, dir: dir, client: client}, nil
+}
+
+// NewReplayer returns a Transport that answers requests from the fixtures in
+// dir, without network access.
+func NewReplayer(dir string) *Transport {
+	return &Transport{mode: ModeReplay, dir: dir}
+}
+
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+// NewClient returns a Bedrock runtime client for region that sends its
+// requests through t. Recording clients use the default AWS configuration;
+// replaying clients need no credentials and do not retry.
+func (t *Transport) NewClient(ctx context.Context, region string, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.Client, error) {
+
+	optFns = append([]func(*bedrockruntime.Options){func(o *bedrockruntime.Options) { o.HTTPClient = t }}, optFns...)
+
+	if t.mode == ModeReplay {
+		return bedrockruntime.New(bedrockruntime.Options{
+			Region:      region,
+			Credentials: aws.AnonymousCredentials{},
+			Retryer:     aws.NopRetryer{},
+		}, optFns...), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	return bedrockruntime.NewFromConfig(cfg, optFns...), nil
+}
+
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+
+	modelID, operation, err := parsePath(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	path := t.path(modelID, operation, body)
+
+	if t.mode == ModeReplay {
+		return replayFixture(req, path)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		ModelID:   modelID,
+		Operation: operation,
+		Request:   string(body),
+		Status:    resp.StatusCode,
+		Headers:   map[string]string{},
+	}
+	for name := range resp.Header {
+		fixture.Headers[name] = resp.Header.Get(name)
+	}
+
+	if operation == OperationInvokeModelWithResponseStream && resp.StatusCode == http.StatusOK {
+		// the stream is written once the caller has read it
+		resp.Body = &recordingBody{ReadCloser: resp.Body, fixture: fixture, path: path}
+		return resp, nil
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	fixture.Body = string(respBody)
+
+	return resp, writeFixture(path, fixture)
+}
+
+// path names fixtures after the model and a hash of the operation and the
+// request body, ignoring JSON formatting.
+func (t *Transport) path(modelID, operation string, body []byte) string {
+
+	var compact bytes.Buffer
+	if json.Compact(&compact, body) == nil {
+		body = compact.Bytes()
+	}
+
+	h := sha256.New()
+	h.Write([]byte(operation))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(modelID)
+	return filepath.Join(t.dir, name, hex.EncodeToString(h.Sum(nil))[:16]+".json")
+}
+
+// parsePath extracts the model ID and operation from a request path such as
+// /model/anthropic.claude-v2/invoke.
+func parsePath(u *url.URL) (string, string, error) {
+
+	path := u.EscapedPath()
+
+	_, rest, ok := strings.Cut(path, "/model/")
+	if !ok {
+		return "", "", fmt.Errorf("not a Bedrock runtime request: %s", path)
+	}
+
+	escapedModelID, action, _ := strings.Cut(rest, "/")
+
+	modelID, err := url.PathUnescape(escapedModelID)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch action {
+	case "invoke":
+		return modelID, OperationInvokeModel, nil
+	case "invoke-with-response-stream":
+		return modelID, OperationInvokeModelWithResponseStream, nil
+	default:
+		return "", "", fmt.Errorf("unsupported Bedrock runtime request: %s", path)
+	}
+}
+
+func replayFixture(req *http.Request, path string) (*http.Response, error) {
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	body := []byte(fixture.Body)
+	if len(fixture.Events) > 0 {
+		body, err = encodeEvents(fixture.Events)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	header := http.Header{}
+	for name, value := range fixture.Headers {
+		header.Set(name, value)
+	}
+	// a re-encoded stream need not have the recorded length
+	header.Del("Content-Length")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func writeFixture(path string, fixture *Fixture) error {
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// recordingBody keeps a copy of a response stream and writes it to a fixture
+// when the stream ends or is closed.
+type recordingBody struct {
+	io.ReadCloser
+	fixture *Fixture
+	path    string
+
+	buf  bytes.Buffer
+	once sync.Once
+	err  error
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.record()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.record()
+	return errors.Join(b.ReadCloser.Close(), b.err)
+}
+
+func (b *recordingBody) record() {
+	b.once.Do(func() {
+		b.fixture.Events, b.err = decodeEvents(b.buf.Bytes())
+		if b.err == nil {
+			b.err = writeFixture(b.path, b.fixture)
+		}
+	})
+}
+
+// chunkPayload is the payload of chunk events.
+type chunkPayload struct {
+	Bytes []byte `json:"bytes"`
+}
+
+func isChunk(headers map[string]string) bool {
+	return headers[eventstreamapi.MessageTypeHeader] == eventstreamapi.EventMessageType &&
+		headers[eventstreamapi.EventTypeHeader] == "chunk"
+}
+
+func decodeEvents(data []byte) ([]Event, error) {
+
+	decoder := eventstream.NewDecoder()
+	r := bytes.NewReader(data)
+
+	var events []Event
+
+	for r.Len() > 0 {
+		msg, err := decoder.Decode(r, nil)
+		if err != nil {
+			return events, err
+		}
+
+		event := Event{Headers: map[string]string{}}
+		for _, h := range msg.Headers {
+			event.Headers[h.Name] = h.Value.String()
+		}
+
+		var chunk chunkPayload
+		if isChunk(event.Headers) && json.Unmarshal(msg.Payload, &chunk) == nil {
+			event.Chunk = string(chunk.Bytes)
+		} else {
+			event.Payload = string(msg.Payload)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func encodeEvents(events []Event) ([]byte, error) {
+
+	encoder := eventstream.NewEncoder()
+	var buf bytes.Buffer
+
+	for _, event := range events {
+
+		var msg eventstream.Message
+		for name, value := range event.Headers {
+			msg.Headers.Set(name, eventstream.StringValue(value))
+		}
+
+		msg.Payload = []byte(event.Payload)
+		if isChunk(event.Headers) {
+			payload, err := json.Marshal(chunkPayload{Bytes: []byte(event.Chunk)})
+			if err != nil {
+				return nil, err
+			}
+			msg.Payload = payload
+		}
+
+		if err := encoder.Encode(&buf, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Chunk returns a chunk event with the model output chunk, for building
+// fixtures by hand.
+func Chunk(chunk string) Event {
+	return Event{
+		Headers: map[string]string{
+			eventstreamapi.MessageTypeHeader: eventstreamapi.EventMessageType,
+			eventstreamapi.EventTypeHeader:   "chunk",
+			eventstreamapi.ContentTypeHeader: "application/json",
+		},
+		Chunk: chunk,
+	}
+}
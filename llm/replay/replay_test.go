@@ -0,0 +1,150 @@
+package replay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/embedding/amazontitan"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/claude"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// bedrock is a local stand-in for the Bedrock runtime API.
+func bedrock(t *testing.T) *httptest.Server {
+
+	stream, err := encodeEvents([]Event{
+		Chunk(`{"completion":"Hel"}`),
+		Chunk(`{"completion":"lo","stop_reason":"stop_sequence"}`),
+	})
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/invoke-with-response-stream"):
+			w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+			w.Write(stream)
+		case strings.Contains(r.URL.Path, "titan-embed"):
+			w.Write([]byte(`{"embedding":[0.5,0.25],"inputTextTokenCount":2}`))
+		default:
+			w.Header().Set("X-Amzn-Bedrock-Input-Token-Count", "12")
+			w.Write([]byte(`{"completion":" Hi!","stop_reason":"stop_sequence"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRecordAndReplay(t *testing.T) {
+
+	dir := t.TempDir()
+	server := bedrock(t)
+	ctx := context.Background()
+
+	run := func(transport *Transport, optFns ...func(*bedrockruntime.Options)) (string, string, []string, []float32) {
+
+		client, err := transport.NewClient(ctx, "us-east-1", optFns...)
+		assert.Nil(t, err)
+
+		claudeLLM, err := claude.New("us-east-1", llm.WithBedrockRuntimeClient(client))
+		assert.Nil(t, err)
+
+		text, err := claudeLLM.Call(ctx, "hello")
+		assert.Nil(t, err)
+
+		var chunks []string
+		streamed, err := claudeLLM.Call(ctx, "hello", llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		}))
+		assert.Nil(t, err)
+
+		embedder, err := amazontitan.New("us-east-1", llm.WithBedrockRuntimeClient(client))
+		assert.Nil(t, err)
+
+		vector, err := embedder.EmbedQuery(ctx, "hello")
+		assert.Nil(t, err)
+
+		return text, streamed, chunks, vector
+	}
+
+	recorder, err := NewRecorder(dir, nil)
+	assert.Nil(t, err)
+
+	text, streamed, chunks, vector := run(recorder, func(o *bedrockruntime.Options) {
+		o.BaseEndpoint = aws.String(server.URL)
+		o.Credentials = aws.AnonymousCredentials{}
+		o.Retryer = aws.NopRetryer{}
+	})
+
+	assert.Equal(t, " Hi!", text)
+	assert.Equal(t, "Hello", streamed)
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+	assert.Equal(t, []float32{0.5, 0.25}, vector)
+
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	assert.Nil(t, err)
+	assert.Len(t, fixtures, 3)
+
+	server.Close()
+
+	replayedText, replayedStream, replayedChunks, replayedVector := run(NewReplayer(dir))
+
+	assert.Equal(t, text, replayedText)
+	assert.Equal(t, streamed, replayedStream)
+	assert.Equal(t, chunks, replayedChunks)
+	assert.Equal(t, vector, replayedVector)
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+
+	client, err := NewReplayer(t.TempDir()).NewClient(context.Background(), "us-east-1")
+	assert.Nil(t, err)
+
+	claudeLLM, err := claude.New("us-east-1", llm.WithBedrockRuntimeClient(client))
+	assert.Nil(t, err)
+
+	_, err = claudeLLM.Call(context.Background(), "hello")
+	assert.ErrorIs(t, err, ErrNoFixture)
+}
+
+func TestStreamFixture(t *testing.T) {
+
+	dir := t.TempDir()
+	server := bedrock(t)
+
+	recorder, err := NewRecorder(dir, nil)
+	assert.Nil(t, err)
+
+	client, err := recorder.NewClient(context.Background(), "us-east-1", func(o *bedrockruntime.Options) {
+		o.BaseEndpoint = aws.String(server.URL)
+		o.Credentials = aws.AnonymousCredentials{}
+	})
+	assert.Nil(t, err)
+
+	claudeLLM, err := claude.New("us-east-1", llm.WithBedrockRuntimeClient(client), llm.WithModel(llm.ModelClaudeV21))
+	assert.Nil(t, err)
+
+	_, err = claudeLLM.Call(context.Background(), "hello", llms.WithStreamingFunc(func(context.Context, []byte) error { return nil }))
+	assert.Nil(t, err)
+
+	fixtures, err := filepath.Glob(filepath.Join(dir, "anthropic.claude-v2_1", "*.json"))
+	assert.Nil(t, err)
+	assert.Len(t, fixtures, 1)
+
+	data, err := os.ReadFile(fixtures[0])
+	assert.Nil(t, err)
+
+	// chunks are stored decoded, so that fixtures can be read and edited
+	assert.Contains(t, string(data), `"chunk": "{\"completion\":\"Hel\"}"`)
+	assert.Contains(t, string(data), `"operation": "InvokeModelWithResponseStream"`)
+}
@@ -0,0 +1,322 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// ErrStreamAborted is returned, wrapping the handler's error, when a streaming
+// handler fails and the response stream is closed early.
+var ErrStreamAborted = errors.New("stream aborted by handler")
+
+// ErrStreamClosed is reported to observers when a Stream is closed before the
+// model finished its response.
+var ErrStreamClosed = errors.New("stream closed before the end of the response")
+
+// StreamAborted wraps err, the error returned by a streaming handler, so that
+// it matches both ErrStreamAborted and err.
+func StreamAborted(err error) error {
+	return fmt.Errorf("%w: %w", ErrStreamAborted, err)
+}
+
+// StreamError is returned when a response stream fails after the invocation
+// started, for example because of a ThrottlingException or
+// ModelStreamErrorException sent in the stream, or a failing handler. Use
+// errors.As to get the partial output and errors.Is or errors.As on the
+// wrapped error for the cause.
+type StreamError struct {
+	Err error
+	// Partial is the text received before the failure.
+	Partial    string
+	StopReason string
+	Usage      Usage
+}
+
+func (e *StreamError) Error() string {
+	return "response stream failed: " + e.Err.Error()
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+type StreamEventType int
+
+const (
+	// StreamEventText carries the next piece of generated text in Text.
+	StreamEventText StreamEventType = iota + 1
+	// StreamEventStop carries the reason the model stopped in StopReason.
+	StreamEventStop
+	// StreamEventUsage carries the token counts of the invocation in Usage.
+	StreamEventUsage
+)
+
+// StreamEvent is a single event read from a Stream.
+type StreamEvent struct {
+	Type       StreamEventType
+	Text       string
+	StopReason string
+	Usage      Usage
+}
+
+// ChunkDecoder turns the payload of a single response stream chunk into
+// events. Each model family has its own chunk format.
+type ChunkDecoder func(chunk []byte) ([]StreamEvent, error)
+
+// JSONChunkDecoder returns a ChunkDecoder for model families whose chunks are
+// JSON objects of type T. text and stopReason extract the generated text and
+// the stop reason (empty if not present) from a chunk; the invocation metrics
+// Bedrock adds to the last chunk are decoded into a StreamEventUsage.
+func JSONChunkDecoder[T any](text, stopReason func(*T) string) ChunkDecoder {
+	return func(chunk []byte) ([]StreamEvent, error) {
+
+		var c T
+		err := json.Unmarshal(chunk, &c)
+		if err != nil {
+			return nil, err
+		}
+
+		var m struct {
+			Metrics *InvocationMetrics `json:"amazon-bedrock-invocationMetrics"`
+		}
+		err = json.Unmarshal(chunk, &m)
+		if err != nil {
+			return nil, err
+		}
+
+		var events []StreamEvent
+
+		if t := text(&c); t != "" {
+			events = append(events, StreamEvent{Type: StreamEventText, Text: t})
+		}
+		if r := stopReason(&c); r != "" {
+			events = append(events, StreamEvent{Type: StreamEventStop, StopReason: r})
+		}
+		if m.Metrics != nil {
+			events = append(events, StreamEvent{Type: StreamEventUsage, Usage: m.Metrics.Usage()})
+		}
+
+		return events, nil
+	}
+}
+
+// ProcessStreamingOutput reads output to the end with decode, passing every
+// piece of text to handler, and returns the complete text. If the stream
+// fails, the text received so far is returned together with a *StreamError.
+func ProcessStreamingOutput(ctx context.Context, output *bedrockruntime.InvokeModelWithResponseStreamOutput, decode ChunkDecoder, handler func(ctx context.Context, chunk []byte) error, logger *slog.Logger) (string, error) {
+
+	stream := NewStream(ctx, output.GetStream(), StreamConfig{
+		Decode:  decode,
+		Handler: handler,
+		Logger:  logger,
+	})
+
+	err := stream.Drain()
+	return stream.Text(), err
+}
+
+// StreamReader is the event stream of an InvokeModelWithResponseStream call.
+type StreamReader interface {
+	Events() <-chan types.ResponseStream
+	Close() error
+	Err() error
+}
+
+// StreamConfig configures a Stream.
+type StreamConfig struct {
+	Decode ChunkDecoder
+	// Handler, if not nil, is called with every piece of text before it is
+	// returned by the stream. An error from Handler ends the stream.
+	Handler func(ctx context.Context, chunk []byte) error
+	// Done, if not nil, is called exactly once, when the stream ends or is
+	// closed.
+	Done func(text, stopReason string, usage Usage, err error)
+	// Logger receives warnings about unexpected stream events. It defaults to
+	// NopLogger.
+	Logger *slog.Logger
+}
+
+// Stream reads the events of a streamed model response:
+//
+//	stream, err := model.Stream(ctx, prompt)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//
+//	for stream.Next() {
+//		event := stream.Current()
+//		...
+//	}
+//	return stream.Err()
+type Stream struct {
+	ctx    context.Context
+	reader StreamReader
+	cfg    StreamConfig
+
+	pending []StreamEvent
+	current StreamEvent
+
+	text       strings.Builder
+	stopReason string
+	usage      Usage
+
+	done bool
+	err  error
+}
+
+// NewStream returns a Stream that reads events from reader.
+func NewStream(ctx context.Context, reader StreamReader, cfg StreamConfig) *Stream {
+	if cfg.Logger == nil {
+		cfg.Logger = NopLogger()
+	}
+	return &Stream{ctx: ctx, reader: reader, cfg: cfg}
+}
+
+// Next advances the stream to the next event, which is then available through
+// Current. It returns false once the stream has ended or failed.
+func (s *Stream) Next() bool {
+
+	for len(s.pending) == 0 {
+		if s.done {
+			return false
+		}
+
+		var event types.ResponseStream
+		var ok bool
+
+		select {
+		case event, ok = <-s.reader.Events():
+		case <-s.ctx.Done():
+			s.finish(s.ctx.Err())
+			return false
+		}
+
+		if !ok {
+			s.finish(s.reader.Err())
+			return false
+		}
+
+		var chunk []byte
+
+		switch v := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+			chunk = v.Value.Bytes
+		case *types.UnknownUnionMember:
+			s.cfg.Logger.WarnContext(s.ctx, "unknown stream event", slog.String("tag", v.Tag))
+			continue
+		default:
+			s.cfg.Logger.WarnContext(s.ctx, "unexpected stream event", slog.String("type", fmt.Sprintf("%T", v)))
+			continue
+		}
+
+		events, err := s.cfg.Decode(chunk)
+		if err != nil {
+			s.finish(err)
+			return false
+		}
+
+		if err := s.record(events); err != nil {
+			s.finish(err)
+			return false
+		}
+
+		s.pending = events
+	}
+
+	s.current, s.pending = s.pending[0], s.pending[1:]
+	return true
+}
+
+func (s *Stream) record(events []StreamEvent) error {
+
+	for _, event := range events {
+		switch event.Type {
+		case StreamEventText:
+			s.text.WriteString(event.Text)
+			if s.cfg.Handler != nil {
+				if err := s.cfg.Handler(s.ctx, []byte(event.Text)); err != nil {
+					return StreamAborted(err)
+				}
+			}
+		case StreamEventStop:
+			s.stopReason = event.StopReason
+		case StreamEventUsage:
+			s.usage = event.Usage
+		}
+	}
+
+	return nil
+}
+
+// Drain reads the remaining events, closes the stream and returns Err.
+func (s *Stream) Drain() error {
+	for s.Next() {
+	}
+	s.Close()
+	return s.Err()
+}
+
+// Current returns the event read by the last call to Next.
+func (s *Stream) Current() StreamEvent {
+	return s.current
+}
+
+// Err returns the error that ended the stream, if any. It is a *StreamError.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Text returns the text received so far.
+func (s *Stream) Text() string {
+	return s.text.String()
+}
+
+// StopReason returns the reason the model stopped, once it has been received.
+func (s *Stream) StopReason() string {
+	return s.stopReason
+}
+
+// Usage returns the token counts of the invocation, once they have been
+// received.
+func (s *Stream) Usage() Usage {
+	return s.usage
+}
+
+// Close closes the underlying event stream. It is safe to call Close more than
+// once and after the stream has ended.
+func (s *Stream) Close() error {
+	if s.done {
+		return nil
+	}
+
+	s.finish(ErrStreamClosed)
+	s.err = nil
+	return nil
+}
+
+func (s *Stream) finish(err error) {
+	if s.done {
+		return
+	}
+
+	if err != nil {
+		err = &StreamError{Err: err, Partial: s.text.String(), StopReason: s.stopReason, Usage: s.usage}
+	}
+
+	s.done = true
+	s.err = err
+	s.pending = nil
+	s.reader.Close()
+
+	if s.cfg.Done != nil {
+		s.cfg.Done(s.text.String(), s.stopReason, s.usage, err)
+	}
+}
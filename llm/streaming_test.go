@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStreamReader struct {
+	events chan types.ResponseStream
+	err    error
+	closed bool
+}
+
+func newFakeStreamReader(chunks ...string) *fakeStreamReader {
+	r := &fakeStreamReader{events: make(chan types.ResponseStream, len(chunks))}
+	for _, c := range chunks {
+		r.events <- &types.ResponseStreamMemberChunk{Value: types.PayloadPart{Bytes: []byte(c)}}
+	}
+	close(r.events)
+	return r
+}
+
+func (r *fakeStreamReader) Events() <-chan types.ResponseStream { return r.events }
+func (r *fakeStreamReader) Close() error                        { r.closed = true; return nil }
+func (r *fakeStreamReader) Err() error                          { return r.err }
+
+type testChunk struct {
+	Text       string `json:"text"`
+	StopReason string `json:"stop_reason"`
+}
+
+var decodeTestChunk = JSONChunkDecoder(
+	func(c *testChunk) string { return c.Text },
+	func(c *testChunk) string { return c.StopReason },
+)
+
+func TestStream(t *testing.T) {
+
+	reader := newFakeStreamReader(
+		`{"text":"Hello"}`,
+		`{"text":", world","stop_reason":"stop_sequence","amazon-bedrock-invocationMetrics":{"inputTokenCount":3,"outputTokenCount":4}}`,
+	)
+
+	var doneText string
+	var doneErr error
+	doneCalls := 0
+
+	stream := NewStream(context.Background(), reader, StreamConfig{
+		Decode: decodeTestChunk,
+		Done: func(text, stopReason string, usage Usage, err error) {
+			doneCalls++
+			doneText, doneErr = text, err
+		},
+	})
+
+	var events []StreamEvent
+	for stream.Next() {
+		events = append(events, stream.Current())
+	}
+
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, []StreamEvent{
+		{Type: StreamEventText, Text: "Hello"},
+		{Type: StreamEventText, Text: ", world"},
+		{Type: StreamEventStop, StopReason: "stop_sequence"},
+		{Type: StreamEventUsage, Usage: Usage{InputTokens: 3, OutputTokens: 4}},
+	}, events)
+	assert.Equal(t, "Hello, world", stream.Text())
+	assert.True(t, reader.closed)
+
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, 1, doneCalls)
+	assert.Equal(t, "Hello, world", doneText)
+	assert.NoError(t, doneErr)
+}
+
+func TestStreamHandlerError(t *testing.T) {
+
+	reader := newFakeStreamReader(`{"text":"one"}`, `{"text":"two"}`)
+	errStop := errors.New("stop")
+
+	stream := NewStream(context.Background(), reader, StreamConfig{
+		Decode: decodeTestChunk,
+		Handler: func(ctx context.Context, chunk []byte) error {
+			return errStop
+		},
+	})
+
+	assert.False(t, stream.Next())
+	assert.ErrorIs(t, stream.Err(), ErrStreamAborted)
+	assert.ErrorIs(t, stream.Err(), errStop)
+	assert.Equal(t, "one", stream.Text())
+	assert.True(t, reader.closed)
+}
+
+func TestStreamClose(t *testing.T) {
+
+	reader := newFakeStreamReader(`{"text":"one"}`, `{"text":"two"}`)
+
+	var doneErr error
+	stream := NewStream(context.Background(), reader, StreamConfig{
+		Decode: decodeTestChunk,
+		Done: func(text, stopReason string, usage Usage, err error) {
+			doneErr = err
+		},
+	})
+
+	assert.True(t, stream.Next())
+	assert.NoError(t, stream.Close())
+	assert.False(t, stream.Next())
+	assert.NoError(t, stream.Err())
+	assert.ErrorIs(t, doneErr, ErrStreamClosed)
+}
+
+func TestStreamException(t *testing.T) {
+
+	reader := newFakeStreamReader(`{"text":"partial"}`)
+	reader.err = &types.ThrottlingException{Message: aws.String("slow down")}
+
+	var doneErr error
+	stream := NewStream(context.Background(), reader, StreamConfig{
+		Decode: decodeTestChunk,
+		Done: func(text, stopReason string, usage Usage, err error) {
+			doneErr = err
+		},
+	})
+
+	assert.True(t, stream.Next())
+	assert.False(t, stream.Next())
+
+	var streamErr *StreamError
+	assert.ErrorAs(t, stream.Err(), &streamErr)
+	assert.Equal(t, "partial", streamErr.Partial)
+	assert.True(t, IsThrottling(stream.Err()))
+	assert.Equal(t, stream.Err(), doneErr)
+	assert.True(t, reader.closed)
+}
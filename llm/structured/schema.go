@@ -0,0 +1,306 @@
+package structured
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedType = errors.New("type cannot be described by a JSON schema")
+
+// Schema is the subset of JSON Schema used to describe and validate model
+// output.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false for objects that take no properties
+	// besides Properties.
+	AdditionalProperties *bool   `json:"additionalProperties,omitempty"`
+	Items                *Schema `json:"items,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaFor derives the schema of the JSON encoding of T.
+//
+// Struct fields are named and omitted as by encoding/json. Fields are
+// required unless they are pointers or tagged omitempty, and no other
+// properties are allowed. A description tag describes a field to the model,
+// and an enum tag lists the values a string field may take, separated by
+// commas:
+//
+//	type Ticket struct {
+//		Summary  string `json:"summary" description:"one sentence"`
+//		Priority string `json:"priority" enum:"low,medium,high"`
+//	}
+func SchemaFor[T any]() (*Schema, error) {
+	return SchemaOf(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// SchemaOf derives the schema of the JSON encoding of values of type t, like
+// SchemaFor.
+func SchemaOf(t reflect.Type) (*Schema, error) {
+	return schemaOf(t, map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &Schema{}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// the encoding is up to the type
+		return &Schema{}, nil
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// base64
+			return &Schema{Type: "string"}, nil
+		}
+		items, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+		}
+		return &Schema{Type: "object"}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			// recursive types are not described below the first level
+			return &Schema{}, nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: new(bool)}
+		if err := addFields(s, t, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+}
+
+// addFields adds the properties of the fields of struct type t to s,
+// including those of embedded structs.
+func addFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addFields(s, ft, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaOf(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		property.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property.Enum = strings.Split(enum, ",")
+		}
+
+		s.Properties[name] = property
+
+		if field.Type.Kind() != reflect.Pointer && !slices.Contains(strings.Split(flags, ","), "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return nil
+}
+
+// ValidationError describes how a JSON value does not conform to a schema.
+type ValidationError struct {
+	// Path locates the offending value, such as $.items[2].name.
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks that data is a single JSON value conforming to s, and
+// returns a *ValidationError if it does not.
+func (s *Schema) Validate(data []byte) error {
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Path: "$", Message: "invalid JSON: " + err.Error()}
+	}
+	if decoder.More() {
+		return &ValidationError{Path: "$", Message: "unexpected content after the JSON value"}
+	}
+
+	return s.validate(value, "$")
+}
+
+func (s *Schema) validate(value any, path string) error {
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", s.Type, typeOf(value))}
+	}
+
+	if len(s.Enum) > 0 {
+		str, _ := value.(string)
+		if !slices.Contains(s.Enum, str) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("must be one of %s", strings.Join(quoted(s.Enum), ", "))}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("unknown property %q", name)}
+				}
+				continue
+			}
+			if v[name] == nil && !slices.Contains(s.Required, name) {
+				// optional properties may be null
+				continue
+			}
+			if err := property.validate(v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range v {
+			if err := s.Items.validate(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	default:
+		return typeOf(value) == typ
+	}
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func quoted(values []string) []string {
+	q := make([]string, len(values))
+	for i, v := range values {
+		q[i] = strconv.Quote(v)
+	}
+	return q
+}
@@ -0,0 +1,330 @@
+// Package structured gets JSON output conforming to a schema from an
+// llms.LLM. The schema is derived from a Go type or given explicitly, the
+// model is instructed to follow it, and invalid responses are sent back to the
+// model with the validation error for a bounded number of repairs.
+//
+//	type Invoice struct {
+//		Number string  `json:"number"`
+//		Total  float64 `json:"total" description:"including tax"`
+//	}
+//
+//	invoice, err := structured.Generate[Invoice](ctx, claudeLLM, "Extract the invoice:\n"+text,
+//		structured.WithCallOptions(llms.WithMaxTokens(500)))
+package structured
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	defaultMaxRepairs = 2
+	defaultToolName   = "respond"
+
+	instructionsFormat = "%s\n\nRespond with a JSON value that conforms to this JSON schema, and nothing else:\n%s"
+	repairFormat       = "%s\n\nYour previous response was:\n%s\n\nIt is not valid: %s\nRespond again with only the corrected JSON value."
+)
+
+var (
+	ErrMissingLLM         = errors.New("missing llm")
+	ErrMissingSchema      = errors.New("missing schema")
+	ErrEmptyResponse      = errors.New("empty response")
+	ErrInvalidOutput      = errors.New("model output does not conform to the schema")
+	ErrPrefillUnsupported = errors.New("prefill unsupported")
+)
+
+// Prefiller is implemented by LLMs that can begin the model response with a
+// given text (claude.LLM does). Prefilling "{" has Claude answer with the JSON
+// object right away, without a preamble.
+type Prefiller interface {
+	GeneratePrefilled(ctx context.Context, prompt, prefill string, options ...llms.CallOption) ([]*llms.Generation, error)
+}
+
+// Strategy is how the model is made to answer with JSON.
+type Strategy int
+
+const (
+	// StrategyAuto prefills LLMs that implement Prefiller when the schema is
+	// an object or an array, and otherwise uses StrategyPrompt.
+	StrategyAuto Strategy = iota
+	// StrategyPrompt adds the schema and instructions to the prompt.
+	StrategyPrompt
+	// StrategyPrefill adds the schema and instructions to the prompt and
+	// begins the response with "{" or "[".
+	StrategyPrefill
+	// StrategyTool passes the schema as the parameters of a function the
+	// model is forced to call, for LLMs that support llms.WithFunctions. The
+	// function call arguments are the output; LLMs that ignore the function
+	// are answered from their text.
+	StrategyTool
+)
+
+// OutputError is returned when the model output is still invalid after all
+// repairs. It matches ErrInvalidOutput with errors.Is.
+type OutputError struct {
+	// Text is the last model output.
+	Text     string
+	Attempts int
+	// Err is the last validation error, usually a *ValidationError.
+	Err error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("%s after %d attempts: %s", ErrInvalidOutput, e.Attempts, e.Err)
+}
+
+func (e *OutputError) Unwrap() []error {
+	return []error{ErrInvalidOutput, e.Err}
+}
+
+type Option func(*Options)
+
+type Options struct {
+	Strategy Strategy
+	// MaxRepairs is the number of times an invalid output is sent back to the
+	// model for correction.
+	MaxRepairs int
+	// CallOptions are passed to the LLM on every call.
+	CallOptions []llms.CallOption
+	// ToolName and ToolDescription describe the function of StrategyTool.
+	ToolName        string
+	ToolDescription string
+}
+
+func WithStrategy(s Strategy) Option {
+	return func(o *Options) {
+		o.Strategy = s
+	}
+}
+
+// WithMaxRepairs sets the number of repair attempts (2 by default). Zero
+// disables repairs.
+func WithMaxRepairs(n int) Option {
+	return func(o *Options) {
+		o.MaxRepairs = n
+	}
+}
+
+func WithCallOptions(options ...llms.CallOption) Option {
+	return func(o *Options) {
+		o.CallOptions = append(o.CallOptions, options...)
+	}
+}
+
+// WithTool names and describes the function of StrategyTool.
+func WithTool(name, description string) Option {
+	return func(o *Options) {
+		o.ToolName = name
+		o.ToolDescription = description
+	}
+}
+
+// Generate answers prompt with a value of type T, decoded from model output
+// that conforms to the schema of T (see SchemaFor). Output that does not
+// decode into T is repaired like output that does not conform to the schema.
+func Generate[T any](ctx context.Context, llm llms.LLM, prompt string, options ...Option) (T, error) {
+
+	var value T
+
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return value, err
+	}
+
+	_, err = generate(ctx, llm, prompt, schema, func(data []byte) error {
+		value = *new(T)
+		return json.Unmarshal(data, &value)
+	}, options)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return value, nil
+}
+
+// GenerateJSON answers prompt with JSON that conforms to schema.
+func GenerateJSON(ctx context.Context, llm llms.LLM, prompt string, schema *Schema, options ...Option) (json.RawMessage, error) {
+	return generate(ctx, llm, prompt, schema, nil, options)
+}
+
+func generate(ctx context.Context, llm llms.LLM, prompt string, schema *Schema, decode func([]byte) error, options []Option) (json.RawMessage, error) {
+
+	if llm == nil {
+		return nil, ErrMissingLLM
+	}
+
+	if schema == nil {
+		return nil, ErrMissingSchema
+	}
+
+	opts := &Options{MaxRepairs: defaultMaxRepairs, ToolName: defaultToolName}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	g, err := newGenerator(llm, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	request := g.prompt(prompt)
+
+	for attempt := 1; ; attempt++ {
+
+		text, err := g.generate(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		data := extract(text, schema.Type)
+
+		err = schema.Validate(data)
+		if err == nil && decode != nil {
+			err = decode(data)
+		}
+		if err == nil {
+			return data, nil
+		}
+
+		if attempt > opts.MaxRepairs {
+			return nil, &OutputError{Text: text, Attempts: attempt, Err: err}
+		}
+
+		request = fmt.Sprintf(repairFormat, g.prompt(prompt), text, err)
+	}
+}
+
+// generator calls the LLM according to the strategy.
+type generator struct {
+	llm         llms.LLM
+	strategy    Strategy
+	schemaJSON  string
+	prefill     string
+	callOptions []llms.CallOption
+}
+
+func newGenerator(llm llms.LLM, schema *Schema, opts *Options) (*generator, error) {
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		llm:         llm,
+		strategy:    opts.Strategy,
+		schemaJSON:  string(schemaJSON),
+		prefill:     prefillFor(schema),
+		callOptions: opts.CallOptions,
+	}
+
+	_, isPrefiller := llm.(Prefiller)
+
+	switch g.strategy {
+	case StrategyAuto:
+		g.strategy = StrategyPrompt
+		if isPrefiller && g.prefill != "" {
+			g.strategy = StrategyPrefill
+		}
+	case StrategyPrefill:
+		if !isPrefiller {
+			return nil, fmt.Errorf("%w: %T does not implement Prefiller", ErrPrefillUnsupported, llm)
+		}
+		if g.prefill == "" {
+			return nil, fmt.Errorf("%w: schema type %q is not an object or an array", ErrPrefillUnsupported, schema.Type)
+		}
+	case StrategyTool:
+		g.callOptions = append(g.callOptions[:len(g.callOptions):len(g.callOptions)],
+			llms.WithFunctions([]llms.FunctionDefinition{{
+				Name:        opts.ToolName,
+				Description: opts.ToolDescription,
+				Parameters:  schema,
+			}}),
+			llms.WithFunctionCallBehavior(llms.FunctionCallBehavior(fmt.Sprintf(`{"name": %q}`, opts.ToolName))),
+		)
+	}
+
+	return g, nil
+}
+
+// prompt returns prompt with the instructions of the strategy.
+func (g *generator) prompt(prompt string) string {
+	if g.strategy == StrategyTool {
+		return prompt
+	}
+	return fmt.Sprintf(instructionsFormat, prompt, g.schemaJSON)
+}
+
+// generate returns the model output for prompt.
+func (g *generator) generate(ctx context.Context, prompt string) (string, error) {
+
+	var generations []*llms.Generation
+	var err error
+
+	if g.strategy == StrategyPrefill {
+		generations, err = g.llm.(Prefiller).GeneratePrefilled(ctx, prompt, g.prefill, g.callOptions...)
+	} else {
+		generations, err = g.llm.Generate(ctx, []string{prompt}, g.callOptions...)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(generations) == 0 {
+		return "", ErrEmptyResponse
+	}
+
+	if m := generations[0].Message; m != nil && m.FunctionCall != nil {
+		return m.FunctionCall.Arguments, nil
+	}
+
+	return generations[0].Text, nil
+}
+
+func prefillFor(schema *Schema) string {
+	switch schema.Type {
+	case "object":
+		return "{"
+	case "array":
+		return "["
+	default:
+		return ""
+	}
+}
+
+// extract returns the JSON value in text, skipping any text around it such as
+// a preamble or a Markdown code fence. If there is no well-formed value, it
+// returns text from where the value appears to start, for the validation
+// error to describe.
+func extract(text, typ string) []byte {
+
+	start := -1
+	switch typ {
+	case "object":
+		start = strings.IndexByte(text, '{')
+	case "array":
+		start = strings.IndexByte(text, '[')
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	rest := strings.TrimSpace(text[start:])
+
+	var value json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(rest)).Decode(&value); err != nil {
+		return []byte(rest)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return value
+	}
+	return compact.Bytes()
+}
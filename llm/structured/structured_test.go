@@ -0,0 +1,177 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/llmtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+type lineItem struct {
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+type invoice struct {
+	Number   string     `json:"number" description:"as printed"`
+	Status   string     `json:"status" enum:"paid,unpaid"`
+	Items    []lineItem `json:"items"`
+	Due      *time.Time `json:"due"`
+	Note     string     `json:"note,omitempty"`
+	Internal string     `json:"-"`
+}
+
+// plainLLM hides the prefill support of the fake.
+type plainLLM struct {
+	llms.LLM
+}
+
+func TestSchemaFor(t *testing.T) {
+
+	s, err := SchemaFor[invoice]()
+	assert.Nil(t, err)
+
+	data, err := json.Marshal(s)
+	assert.Nil(t, err)
+
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"number": {"type": "string", "description": "as printed"},
+			"status": {"type": "string", "enum": ["paid", "unpaid"]},
+			"items": {"type": "array", "items": {
+				"type": "object",
+				"properties": {"description": {"type": "string"}, "amount": {"type": "number"}},
+				"required": ["description", "amount"],
+				"additionalProperties": false
+			}},
+			"due": {"type": "string", "format": "date-time"},
+			"note": {"type": "string"}
+		},
+		"required": ["number", "status", "items"],
+		"additionalProperties": false
+	}`, string(data))
+
+	_, err = SchemaFor[chan int]()
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestValidate(t *testing.T) {
+
+	s, err := SchemaFor[invoice]()
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Validate([]byte(`{"number":"A1","status":"paid","items":[],"due":null}`)))
+
+	for data, message := range map[string]string{
+		`{"number":"A1","status":"paid"}`:                                            `$: missing required property "items"`,
+		`{"number":"A1","status":"late","items":[]}`:                                 `$.status: must be one of "paid", "unpaid"`,
+		`{"number":"A1","status":"paid","items":[{"description":"x","amount":"1"}]}`: `$.items[0].amount: expected number, got string`,
+		`{"number":"A1","status":"paid","items":[],"total":3}`:                       `$: unknown property "total"`,
+		`{"number":"A1"`: "$: invalid JSON: unexpected EOF",
+	} {
+		err := s.Validate([]byte(data))
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr, data)
+		assert.EqualError(t, err, message, data)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	fake, err := llmtest.NewLLM([]llmtest.Response{
+		// continues the prefilled "{" and adds a remark
+		{Text: `"number":"A1","status":"paid","items":[{"description":"Widget","amount":9.5}]}` + "\nLet me know if you need more."},
+	})
+	assert.Nil(t, err)
+
+	v, err := Generate[invoice](context.Background(), fake, "Extract the invoice", WithCallOptions(llms.WithMaxTokens(200)))
+	assert.Nil(t, err)
+	assert.Equal(t, invoice{Number: "A1", Status: "paid", Items: []lineItem{{"Widget", 9.5}}}, v)
+
+	calls := fake.Calls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "{", calls[0].Prefill)
+	assert.Equal(t, 200, calls[0].Options.MaxTokens)
+	assert.True(t, strings.HasPrefix(calls[0].Prompt, "Extract the invoice\n\n"))
+	assert.Contains(t, calls[0].Prompt, `"required":["number","status","items"]`)
+}
+
+func TestGenerateRepairs(t *testing.T) {
+
+	fake, err := llmtest.NewLLM([]llmtest.Response{
+		{Text: "Sure! ```json\n{\"number\":\"A1\",\"status\":\"overdue\",\"items\":[]}\n```"},
+		{Text: `{"number":"A1","status":"unpaid","items":[]}`},
+	})
+	assert.Nil(t, err)
+
+	s, err := SchemaFor[invoice]()
+	assert.Nil(t, err)
+
+	data, err := GenerateJSON(context.Background(), plainLLM{fake}, "Extract the invoice", s)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"number":"A1","status":"unpaid","items":[]}`, string(data))
+
+	calls := fake.Calls()
+	assert.Len(t, calls, 2)
+	assert.Empty(t, calls[0].Prefill)
+	assert.Contains(t, calls[1].Prompt, "Your previous response was:\nSure!")
+	assert.Contains(t, calls[1].Prompt, `$.status: must be one of "paid", "unpaid"`)
+}
+
+func TestGenerateGivesUp(t *testing.T) {
+
+	fake, err := llmtest.NewLLM(nil)
+	assert.Nil(t, err)
+	fake.RespondWith(func(string, llms.CallOptions) llmtest.Response {
+		return llmtest.Response{Text: `"number":"A1"}`}
+	})
+
+	_, err = Generate[invoice](context.Background(), fake, "Extract the invoice", WithMaxRepairs(1))
+
+	var outputErr *OutputError
+	assert.ErrorAs(t, err, &outputErr)
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+	assert.Equal(t, 2, outputErr.Attempts)
+	assert.Equal(t, `{"number":"A1"}`, outputErr.Text)
+	assert.Len(t, fake.Calls(), 2)
+
+	// LLM errors are not repaired
+	failure := errors.New("throttled")
+	fake.Respond(llmtest.Response{Err: failure})
+
+	_, err = Generate[invoice](context.Background(), fake, "Extract the invoice")
+	assert.ErrorIs(t, err, failure)
+	assert.Len(t, fake.Calls(), 3)
+
+	_, err = Generate[invoice](context.Background(), plainLLM{fake}, "Extract the invoice", WithStrategy(StrategyPrefill))
+	assert.ErrorIs(t, err, ErrPrefillUnsupported)
+}
+
+func TestGenerateWithTool(t *testing.T) {
+
+	fake, err := llmtest.NewLLM([]llmtest.Response{{
+		FunctionCall: &schema.FunctionCall{Name: "record_invoice", Arguments: `{"number":"A1","status":"paid","items":[]}`},
+	}})
+	assert.Nil(t, err)
+
+	v, err := Generate[invoice](context.Background(), fake, "Extract the invoice",
+		WithStrategy(StrategyTool), WithTool("record_invoice", "Records an invoice"))
+	assert.Nil(t, err)
+	assert.Equal(t, "A1", v.Number)
+
+	calls := fake.Calls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "Extract the invoice", calls[0].Prompt)
+	assert.Len(t, calls[0].Options.Functions, 1)
+	assert.Equal(t, "record_invoice", calls[0].Options.Functions[0].Name)
+	assert.Equal(t, llms.FunctionCallBehavior(`{"name": "record_invoice"}`), calls[0].Options.FunctionCallBehavior)
+}
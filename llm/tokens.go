@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// ErrContextWindowExceeded is returned (wrapped) when a prompt plus the
+// requested number of output tokens does not fit in the model context window.
+var ErrContextWindowExceeded = errors.New("prompt exceeds the model context window")
+
+// TokenCounter counts the tokens a model sees for a text. Use WithTokenCounter
+// to plug in an exact (offline) tokenizer.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// Estimator estimates token counts for models whose tokenizer is not
+// available offline. Runs of letters and digits count one token for every
+// CharsPerToken characters (rounded up); CJK characters and every other
+// non-space character count one token each.
+type Estimator struct {
+	CharsPerToken int
+}
+
+func (e Estimator) CountTokens(text string) int {
+
+	tokens := 0
+	word := 0
+
+	endWord := func() {
+		if word > 0 {
+			tokens += (word + e.CharsPerToken - 1) / e.CharsPerToken
+			word = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			endWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+			tokens++
+		}
+	}
+	endWord()
+
+	return tokens
+}
+
+// Estimators for each model family. The larger the vocabulary of the family's
+// tokenizer, the more characters fit in a token. They are not calibrated
+// against the real tokenizers and can be well off, so CheckPromptSize does not
+// act on their counts; plug in an exact tokenizer with WithTokenCounter where
+// counts matter.
+var (
+	ClaudeEstimator = Estimator{CharsPerToken: 5}
+	LlamaEstimator  = Estimator{CharsPerToken: 4}
+	CohereEstimator = Estimator{CharsPerToken: 5}
+	TitanEstimator  = Estimator{CharsPerToken: 4}
+)
+
+var familyEstimators = map[Provider]Estimator{
+	ProviderAnthropic: ClaudeEstimator,
+	ProviderMeta:      LlamaEstimator,
+	ProviderCohere:    CohereEstimator,
+	ProviderAmazon:    TitanEstimator,
+}
+
+// TokenCounterFor returns the estimator for the family of modelID. Unknown
+// families get a conservative estimator.
+func TokenCounterFor(modelID string) TokenCounter {
+	if e, ok := familyEstimators[ProviderOf(modelID)]; ok {
+		return e
+	}
+	return Estimator{CharsPerToken: 3}
+}
+
+// ContextWindow returns the context window of modelID in tokens, and false if
+// the model is not known.
+func ContextWindow(modelID string) (int, bool) {
+	m, ok := LookupModel(modelID)
+	return m.ContextWindow, ok && m.ContextWindow > 0
+}
+
+// CheckPromptSize fails fast, before the model is invoked, if prompt plus
+// maxTokens output tokens does not fit in the context window of modelID. It
+// does nothing for unknown models and for an Estimator, whose over-estimates
+// would reject prompts that fit; Bedrock still rejects prompts that do not.
+func CheckPromptSize(counter TokenCounter, modelID, prompt string, maxTokens int) error {
+
+	if _, ok := counter.(Estimator); ok {
+		return nil
+	}
+
+	window, ok := ContextWindow(modelID)
+	if !ok {
+		return nil
+	}
+
+	tokens := counter.CountTokens(prompt)
+	if tokens+maxTokens > window {
+		return fmt.Errorf("%w: %d prompt tokens plus %d output tokens, %s allows %d", ErrContextWindowExceeded, tokens, maxTokens, modelID, window)
+	}
+
+	return nil
+}
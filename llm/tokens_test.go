@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimator(t *testing.T) {
+
+	e := Estimator{CharsPerToken: 4}
+
+	assert.Equal(t, 0, e.CountTokens(""))
+	assert.Equal(t, 0, e.CountTokens("  \n"))
+	assert.Equal(t, 2, e.CountTokens("hello"))
+	// "what" + "'" + "s" + "your" + "name" + "?"
+	assert.Equal(t, 6, e.CountTokens("what's your name?"))
+	assert.Equal(t, 6, e.CountTokens("日本語の文章"))
+}
+
+func TestTokenCounterFor(t *testing.T) {
+
+	assert.Equal(t, ClaudeEstimator, TokenCounterFor("anthropic.claude-v2"))
+	assert.Equal(t, LlamaEstimator, TokenCounterFor("meta.llama2-13b-chat-v1"))
+	assert.Equal(t, CohereEstimator, TokenCounterFor("cohere.command-text-v14"))
+	assert.Equal(t, Estimator{CharsPerToken: 3}, TokenCounterFor("unknown.model"))
+}
+
+// wordCounter counts whitespace-separated words.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestCheckPromptSize(t *testing.T) {
+
+	prompt := strings.Repeat("word ", 4000)
+
+	assert.NoError(t, CheckPromptSize(wordCounter{}, "meta.llama2-13b-chat-v1", prompt, 96))
+	assert.ErrorIs(t, CheckPromptSize(wordCounter{}, "meta.llama2-13b-chat-v1", prompt, 97), ErrContextWindowExceeded)
+	assert.NoError(t, CheckPromptSize(wordCounter{}, "unknown.model", prompt+prompt, 1000))
+
+	// estimates are never held against a prompt
+	assert.NoError(t, CheckPromptSize(LlamaEstimator, "meta.llama2-13b-chat-v1", prompt+prompt, 1000))
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/abhirockzz/amazon-bedrock-langchain-go"
+
+// Attribute keys and values from the OpenTelemetry semantic conventions for
+// generative AI systems.
+const (
+	AttrSystem                = attribute.Key("gen_ai.system")
+	AttrOperationName         = attribute.Key("gen_ai.operation.name")
+	AttrRequestModel          = attribute.Key("gen_ai.request.model")
+	AttrRequestMaxTokens      = attribute.Key("gen_ai.request.max_tokens")
+	AttrRequestTemperature    = attribute.Key("gen_ai.request.temperature")
+	AttrRequestTopP           = attribute.Key("gen_ai.request.top_p")
+	AttrRequestTopK           = attribute.Key("gen_ai.request.top_k")
+	AttrRequestStopSequences  = attribute.Key("gen_ai.request.stop_sequences")
+	AttrResponseFinishReasons = attribute.Key("gen_ai.response.finish_reasons")
+	AttrUsageInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	AttrUsageOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+
+	SystemBedrock = "aws.bedrock"
+
+	OperationTextCompletion = "text_completion"
+	OperationEmbeddings     = "embeddings"
+
+	EventFirstToken = "gen_ai.stream.first_token"
+	EventCompletion = "gen_ai.stream.completion"
+)
+
+// Tracer returns the tracer used by this module for tp, or a no-op tracer if
+// tp is nil.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// SpanParams are the request details recorded on an invocation span. Zero
+// values are omitted.
+type SpanParams struct {
+	Operation     string
+	ModelID       string
+	MaxTokens     int
+	Temperature   float64
+	TopP          float64
+	TopK          int
+	StopSequences []string
+}
+
+// StartSpan starts the client span for a single Bedrock invocation.
+func StartSpan(ctx context.Context, tracer trace.Tracer, params SpanParams) (context.Context, trace.Span) {
+
+	attrs := []attribute.KeyValue{
+		AttrSystem.String(SystemBedrock),
+		AttrOperationName.String(params.Operation),
+		AttrRequestModel.String(params.ModelID),
+	}
+
+	if params.MaxTokens > 0 {
+		attrs = append(attrs, AttrRequestMaxTokens.Int(params.MaxTokens))
+	}
+	if params.Temperature > 0 {
+		attrs = append(attrs, AttrRequestTemperature.Float64(params.Temperature))
+	}
+	if params.TopP > 0 {
+		attrs = append(attrs, AttrRequestTopP.Float64(params.TopP))
+	}
+	if params.TopK > 0 {
+		attrs = append(attrs, AttrRequestTopK.Int(params.TopK))
+	}
+	if len(params.StopSequences) > 0 {
+		attrs = append(attrs, AttrRequestStopSequences.StringSlice(params.StopSequences))
+	}
+
+	return tracer.Start(ctx, params.Operation+" "+params.ModelID,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// EndSpan records the outcome of an invocation and ends span.
+func EndSpan(span trace.Span, usage Usage, finishReason string, err error) {
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		if finishReason != "" {
+			span.SetAttributes(AttrResponseFinishReasons.StringSlice([]string{finishReason}))
+		}
+		if usage.InputTokens > 0 {
+			span.SetAttributes(AttrUsageInputTokens.Int(usage.InputTokens))
+		}
+		if usage.OutputTokens > 0 {
+			span.SetAttributes(AttrUsageOutputTokens.Int(usage.OutputTokens))
+		}
+	}
+
+	span.End()
+}
+
+// TraceStreamingFunc wraps handler so that span gets an event when the first
+// chunk arrives and another one once the stream has been consumed (see
+// EndStreamSpan).
+func TraceStreamingFunc(span trace.Span, handler func(ctx context.Context, chunk []byte) error) func(ctx context.Context, chunk []byte) error {
+	first := true
+	return func(ctx context.Context, chunk []byte) error {
+		if first {
+			first = false
+			span.AddEvent(EventFirstToken)
+		}
+		return handler(ctx, chunk)
+	}
+}
+
+// EndStreamSpan is EndSpan for streamed invocations.
+func EndStreamSpan(span trace.Span, usage Usage, finishReason string, err error) {
+	if err == nil {
+		span.AddEvent(EventCompletion)
+	}
+	EndSpan(span, usage, finishReason, err)
+}
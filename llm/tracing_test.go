@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm/oteltest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestStreamSpan(t *testing.T) {
+
+	tp, exporter := oteltest.NewTracerProvider()
+
+	ctx, span := StartSpan(context.Background(), Tracer(tp), SpanParams{Operation: OperationTextCompletion, ModelID: "anthropic.claude-v2", MaxTokens: 100})
+
+	handler := TraceStreamingFunc(span, func(ctx context.Context, chunk []byte) error {
+		return nil
+	})
+	assert.Nil(t, handler(ctx, []byte("hello")))
+	assert.Nil(t, handler(ctx, []byte(" world")))
+
+	EndStreamSpan(span, Usage{InputTokens: 10, OutputTokens: 2}, "stop_sequence", nil)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "text_completion anthropic.claude-v2", spans[0].Name)
+
+	assert.Contains(t, spans[0].Attributes, AttrSystem.String(SystemBedrock))
+	assert.Contains(t, spans[0].Attributes, AttrRequestMaxTokens.Int(100))
+	assert.Contains(t, spans[0].Attributes, AttrUsageInputTokens.Int(10))
+	assert.Contains(t, spans[0].Attributes, attribute.StringSlice(string(AttrResponseFinishReasons), []string{"stop_sequence"}))
+
+	assert.Equal(t, 2, len(spans[0].Events))
+	assert.Equal(t, EventFirstToken, spans[0].Events[0].Name)
+	assert.Equal(t, EventCompletion, spans[0].Events[1].Name)
+}
+
+func TestSpanError(t *testing.T) {
+
+	tp, exporter := oteltest.NewTracerProvider()
+
+	_, span := StartSpan(context.Background(), Tracer(tp), SpanParams{Operation: OperationEmbeddings, ModelID: "amazon.titan-embed-text-v1"})
+	EndSpan(span, Usage{}, "", errors.New("throttled"))
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
@@ -0,0 +1,167 @@
+// Package metrics provides Prometheus collectors for the LLM and embedding
+// types in this module. Attach a Collector with llm.WithMetrics and register
+// it with a prometheus.Registerer.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const subsystem = "bedrock"
+
+// latencyBuckets cover everything from short embedding calls to long
+// generations, in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80}
+
+const (
+	OutcomeSuccess   = "success"
+	OutcomeThrottled = "throttled"
+	OutcomeError     = "error"
+)
+
+type Collector struct {
+	requests      *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	firstToken    *prometheus.HistogramVec
+	inputTokens   *prometheus.CounterVec
+	outputTokens  *prometheus.CounterVec
+	embeddedTexts *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	throttles     *prometheus.CounterVec
+	circuitState  *prometheus.GaugeVec
+	transitions   *prometheus.CounterVec
+	hedging       *prometheus.CounterVec
+	hedged        *prometheus.CounterVec
+	hedgeWins     *prometheus.CounterVec
+}
+
+var (
+	_ llm.MetricsRecorder        = (*Collector)(nil)
+	_ llm.CircuitMetricsRecorder = (*Collector)(nil)
+	_ llm.HedgeMetricsRecorder   = (*Collector)(nil)
+	_ prometheus.Collector       = (*Collector)(nil)
+)
+
+// New creates a Collector whose metric names are prefixed with namespace
+// (for example "myservice_bedrock_requests_total"). namespace may be empty.
+func New(namespace string) *Collector {
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      help,
+			Buckets:   latencyBuckets,
+		}, labels)
+	}
+
+	gauge := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	return &Collector{
+		requests:      counter("requests_total", "Bedrock invocations by model, operation and outcome.", "model", "operation", "outcome"),
+		latency:       histogram("request_duration_seconds", "Bedrock invocation latency.", "model", "operation"),
+		firstToken:    histogram("time_to_first_token_seconds", "Time until the first chunk of a streamed response.", "model"),
+		inputTokens:   counter("input_tokens_total", "Input tokens reported by Bedrock.", "model"),
+		outputTokens:  counter("output_tokens_total", "Output tokens reported by Bedrock.", "model"),
+		embeddedTexts: counter("embedded_texts_total", "Texts embedded.", "model"),
+		retries:       counter("retries_total", "Invocation attempts retried by the AWS SDK.", "model"),
+		throttles:     counter("throttles_total", "Invocation attempts rejected with a throttling error, including retried ones.", "model"),
+		circuitState:  gauge("circuit_state", "Circuit breaker state by model: 0 closed, 1 open, 2 half-open.", "model", "breaker"),
+		transitions:   counter("circuit_transitions_total", "Circuit breaker state changes by model and new state.", "model", "breaker", "to"),
+		hedging:       counter("hedging_requests_total", "Invocations made with hedging enabled.", "model"),
+		hedged:        counter("hedged_requests_total", "Invocations for which a duplicate was sent.", "model"),
+		hedgeWins:     counter("hedge_wins_total", "Hedged invocations answered by the duplicate.", "model"),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.requests, c.latency, c.firstToken, c.inputTokens, c.outputTokens, c.embeddedTexts, c.retries, c.throttles, c.circuitState, c.transitions, c.hedging, c.hedged, c.hedgeWins}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, col := range c.collectors() {
+		col.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, col := range c.collectors() {
+		col.Collect(ch)
+	}
+}
+
+func (c *Collector) RecordInvocation(_ context.Context, stats llm.InvocationStats) {
+
+	outcome := OutcomeSuccess
+	if stats.Err != nil {
+		outcome = OutcomeError
+		if llm.IsThrottling(stats.Err) {
+			outcome = OutcomeThrottled
+		}
+	}
+
+	if stats.Throttles > 0 {
+		c.throttles.WithLabelValues(stats.ModelID).Add(float64(stats.Throttles))
+	}
+
+	c.requests.WithLabelValues(stats.ModelID, stats.Operation, outcome).Inc()
+	c.latency.WithLabelValues(stats.ModelID, stats.Operation).Observe(stats.Latency.Seconds())
+
+	if stats.Retries > 0 {
+		c.retries.WithLabelValues(stats.ModelID).Add(float64(stats.Retries))
+	}
+
+	if stats.Usage.InputTokens > 0 {
+		c.inputTokens.WithLabelValues(stats.ModelID).Add(float64(stats.Usage.InputTokens))
+	}
+
+	if stats.Usage.OutputTokens > 0 {
+		c.outputTokens.WithLabelValues(stats.ModelID).Add(float64(stats.Usage.OutputTokens))
+	}
+}
+
+func (c *Collector) RecordTimeToFirstToken(_ context.Context, modelID string, d time.Duration) {
+	c.firstToken.WithLabelValues(modelID).Observe(d.Seconds())
+}
+
+func (c *Collector) RecordEmbeddedTexts(_ context.Context, modelID string, n int) {
+	c.embeddedTexts.WithLabelValues(modelID).Add(float64(n))
+}
+
+func (c *Collector) RecordCircuitStateChange(_ context.Context, change llm.CircuitStateChange) {
+	c.circuitState.WithLabelValues(change.ModelID, change.Name).Set(float64(change.To))
+	c.transitions.WithLabelValues(change.ModelID, change.Name, change.To.String()).Inc()
+}
+
+// RecordHedge counts hedging. The hedge rate is hedged_requests_total divided
+// by hedging_requests_total.
+func (c *Collector) RecordHedge(_ context.Context, modelID string, hedged, hedgeWon bool) {
+	c.hedging.WithLabelValues(modelID).Inc()
+	if hedged {
+		c.hedged.WithLabelValues(modelID).Inc()
+	}
+	if hedgeWon {
+		c.hedgeWins.WithLabelValues(modelID).Inc()
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhirockzz/amazon-bedrock-langchain-go/llm"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordInvocation(t *testing.T) {
+
+	c := New("test")
+
+	reg := prometheus.NewRegistry()
+	assert.Nil(t, reg.Register(c))
+
+	ctx := context.Background()
+
+	c.RecordInvocation(ctx, llm.InvocationStats{ModelID: "anthropic.claude-v2", Operation: llm.OperationTextCompletion, Latency: time.Second, Usage: llm.Usage{InputTokens: 10, OutputTokens: 5}, Retries: 2})
+	c.RecordInvocation(ctx, llm.InvocationStats{ModelID: "anthropic.claude-v2", Operation: llm.OperationTextCompletion, Throttles: 1, Err: &types.ThrottlingException{}})
+	// throttled, retried, then failed for another reason
+	c.RecordInvocation(ctx, llm.InvocationStats{ModelID: "anthropic.claude-v2", Operation: llm.OperationTextCompletion, Retries: 2, Throttles: 2, Err: errors.New("boom")})
+	c.RecordTimeToFirstToken(ctx, "anthropic.claude-v2", 300*time.Millisecond)
+	c.RecordEmbeddedTexts(ctx, "amazon.titan-embed-text-v1", 3)
+
+	expected := `
+# HELP test_bedrock_requests_total Bedrock invocations by model, operation and outcome.
+# TYPE test_bedrock_requests_total counter
+test_bedrock_requests_total{model="anthropic.claude-v2",operation="text_completion",outcome="error"} 1
+test_bedrock_requests_total{model="anthropic.claude-v2",operation="text_completion",outcome="success"} 1
+test_bedrock_requests_total{model="anthropic.claude-v2",operation="text_completion",outcome="throttled"} 1
+# HELP test_bedrock_input_tokens_total Input tokens reported by Bedrock.
+# TYPE test_bedrock_input_tokens_total counter
+test_bedrock_input_tokens_total{model="anthropic.claude-v2"} 10
+# HELP test_bedrock_retries_total Invocation attempts retried by the AWS SDK.
+# TYPE test_bedrock_retries_total counter
+test_bedrock_retries_total{model="anthropic.claude-v2"} 4
+# HELP test_bedrock_throttles_total Invocation attempts rejected with a throttling error, including retried ones.
+# TYPE test_bedrock_throttles_total counter
+test_bedrock_throttles_total{model="anthropic.claude-v2"} 3
+# HELP test_bedrock_embedded_texts_total Texts embedded.
+# TYPE test_bedrock_embedded_texts_total counter
+test_bedrock_embedded_texts_total{model="amazon.titan-embed-text-v1"} 3
+`
+
+	assert.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_bedrock_requests_total", "test_bedrock_input_tokens_total", "test_bedrock_retries_total", "test_bedrock_throttles_total", "test_bedrock_embedded_texts_total"))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "test_bedrock_time_to_first_token_seconds"))
+}
+
+func TestRecordCircuitStateChange(t *testing.T) {
+
+	c := New("test")
+
+	reg := prometheus.NewRegistry()
+	assert.Nil(t, reg.Register(c))
+
+	ctx := context.Background()
+
+	c.RecordCircuitStateChange(ctx, llm.CircuitStateChange{Name: "us-east-1", ModelID: "anthropic.claude-v2", From: llm.CircuitClosed, To: llm.CircuitOpen})
+	c.RecordCircuitStateChange(ctx, llm.CircuitStateChange{Name: "us-east-1", ModelID: "anthropic.claude-v2", From: llm.CircuitOpen, To: llm.CircuitHalfOpen})
+
+	expected := `
+# HELP test_bedrock_circuit_state Circuit breaker state by model: 0 closed, 1 open, 2 half-open.
+# TYPE test_bedrock_circuit_state gauge
+test_bedrock_circuit_state{breaker="us-east-1",model="anthropic.claude-v2"} 2
+# HELP test_bedrock_circuit_transitions_total Circuit breaker state changes by model and new state.
+# TYPE test_bedrock_circuit_transitions_total counter
+test_bedrock_circuit_transitions_total{breaker="us-east-1",model="anthropic.claude-v2",to="half-open"} 1
+test_bedrock_circuit_transitions_total{breaker="us-east-1",model="anthropic.claude-v2",to="open"} 1
+`
+
+	assert.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_bedrock_circuit_state", "test_bedrock_circuit_transitions_total"))
+}
+
+func TestRecordHedge(t *testing.T) {
+
+	c := New("test")
+
+	reg := prometheus.NewRegistry()
+	assert.Nil(t, reg.Register(c))
+
+	ctx := context.Background()
+
+	c.RecordHedge(ctx, "anthropic.claude-v2", false, false)
+	c.RecordHedge(ctx, "anthropic.claude-v2", true, false)
+	c.RecordHedge(ctx, "anthropic.claude-v2", true, true)
+
+	expected := `
+# HELP test_bedrock_hedging_requests_total Invocations made with hedging enabled.
+# TYPE test_bedrock_hedging_requests_total counter
+test_bedrock_hedging_requests_total{model="anthropic.claude-v2"} 3
+# HELP test_bedrock_hedged_requests_total Invocations for which a duplicate was sent.
+# TYPE test_bedrock_hedged_requests_total counter
+test_bedrock_hedged_requests_total{model="anthropic.claude-v2"} 2
+# HELP test_bedrock_hedge_wins_total Hedged invocations answered by the duplicate.
+# TYPE test_bedrock_hedge_wins_total counter
+test_bedrock_hedge_wins_total{model="anthropic.claude-v2"} 1
+`
+
+	assert.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_bedrock_hedging_requests_total", "test_bedrock_hedged_requests_total", "test_bedrock_hedge_wins_total"))
+}